@@ -0,0 +1,286 @@
+package agent
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestType is the kind of request made by a browser. Browsers send
+// different Accept and Sec-Fetch-* headers for each kind.
+type RequestType int
+
+const (
+	// Navigation is a request for a page, e.g. following a link.
+	Navigation RequestType = iota
+	// FormSubmission is a form posted to a page.
+	FormSubmission
+	// ImageRequest is a request for an image.
+	ImageRequest
+	// StylesheetRequest is a request for a stylesheet.
+	StylesheetRequest
+	// ScriptRequest is a request for a script.
+	ScriptRequest
+	// FetchRequest is a request made by the scripts of a page, e.g. with
+	// XMLHttpRequest or fetch.
+	FetchRequest
+)
+
+// Sec-Fetch-Site values describing how the origin of a request relates to
+// the page that made it.
+const (
+	SiteNone       = "none"
+	SiteSameOrigin = "same-origin"
+	SiteSameSite   = "same-site"
+	SiteCrossSite  = "cross-site"
+)
+
+// Profile is the set of headers sent by a well known browser along with its
+// user agent string, so a server sees the headers it expects from the
+// browser named in the User-Agent header.
+type Profile struct {
+	// Browser is the name of the browser in Database.
+	Browser string
+
+	// Version is the browser version.
+	Version string
+
+	// OS holds the attributes of the operating system in the user agent.
+	OS OSAttributes
+
+	// Accept is the Accept header sent for each kind of request.
+	Accept map[RequestType]string
+
+	// AcceptLanguage is the Accept-Language header.
+	AcceptLanguage string
+
+	// AcceptEncoding is the Accept-Encoding header.
+	AcceptEncoding string
+
+	// Brands is the sec-ch-ua header. No client hints are sent when empty.
+	Brands string
+
+	// Mobile is the value of the sec-ch-ua-mobile client hint.
+	Mobile bool
+
+	// Platform is the value of the sec-ch-ua-platform client hint.
+	Platform string
+
+	// FetchMetadata sends the Sec-Fetch-* headers.
+	FetchMetadata bool
+
+	// UpgradeInsecureRequests sends the Upgrade-Insecure-Requests header
+	// with navigations.
+	UpgradeInsecureRequests bool
+
+	// HeaderOrder is the order the browser sends its headers in, spelled
+	// the way it sends them over HTTP/1.1. Entries starting with a colon are
+	// the HTTP/2 pseudo headers. Headers which are not listed are sent after
+	// the listed headers.
+	HeaderOrder []string
+}
+
+// ProfileTable is a collection of Profile values.
+type ProfileTable map[string]Profile
+
+// chromeAccept are the Accept headers sent by Chrome.
+var chromeAccept = map[RequestType]string{
+	Navigation:        "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+	FormSubmission:    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+	ImageRequest:      "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+	StylesheetRequest: "text/css,*/*;q=0.1",
+	ScriptRequest:     "*/*",
+	FetchRequest:      "*/*",
+}
+
+// chromeOrder is the order Chrome sends its headers in.
+var chromeOrder = []string{
+	":method", ":authority", ":scheme", ":path",
+	"Host", "Connection", "Content-Length", "Cache-Control", "sec-ch-ua",
+	"sec-ch-ua-mobile", "sec-ch-ua-platform", "Upgrade-Insecure-Requests",
+	"Origin", "Content-Type", "User-Agent", "Accept", "Sec-Fetch-Site",
+	"Sec-Fetch-Mode", "Sec-Fetch-User", "Sec-Fetch-Dest", "Referer",
+	"Accept-Encoding", "Accept-Language", "Cookie",
+}
+
+// Profiles is the "database" of browser profiles. The Accept-Encoding
+// headers only list the encodings the browser package can decode.
+var Profiles = ProfileTable{
+	"chrome": {
+		Browser:                 "chrome",
+		Version:                 "124.0.0.0",
+		OS:                      OSAttributes{"Windows NT", "10.0", []string{"Win64", "x64"}},
+		Accept:                  chromeAccept,
+		AcceptLanguage:          "en-US,en;q=0.9",
+		AcceptEncoding:          "gzip, deflate, br",
+		Brands:                  `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+		Platform:                "Windows",
+		FetchMetadata:           true,
+		UpgradeInsecureRequests: true,
+		HeaderOrder:             chromeOrder,
+	},
+	"chromemobile": {
+		Browser:                 "chromemobile",
+		Version:                 "124.0.0.0",
+		OS:                      DefaultOSAttributes[Android],
+		Accept:                  chromeAccept,
+		AcceptLanguage:          "en-US,en;q=0.9",
+		AcceptEncoding:          "gzip, deflate, br",
+		Brands:                  `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+		Mobile:                  true,
+		Platform:                "Android",
+		FetchMetadata:           true,
+		UpgradeInsecureRequests: true,
+		HeaderOrder:             chromeOrder,
+	},
+	"firefox": {
+		Browser: "firefox",
+		Version: "125.0",
+		OS:      OSAttributes{"Windows NT", "10.0", []string{"Win64", "x64"}},
+		Accept: map[RequestType]string{
+			Navigation:        "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+			FormSubmission:    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+			ImageRequest:      "image/avif,image/webp,*/*",
+			StylesheetRequest: "text/css,*/*;q=0.1",
+			ScriptRequest:     "*/*",
+			FetchRequest:      "*/*",
+		},
+		AcceptLanguage:          "en-US,en;q=0.5",
+		AcceptEncoding:          "gzip, deflate, br",
+		FetchMetadata:           true,
+		UpgradeInsecureRequests: true,
+		HeaderOrder: []string{
+			":method", ":path", ":authority", ":scheme",
+			"Host", "User-Agent", "Accept", "Accept-Language", "Accept-Encoding",
+			"Content-Type", "Content-Length", "Origin", "Connection", "Referer",
+			"Cookie", "Upgrade-Insecure-Requests", "Sec-Fetch-Dest",
+			"Sec-Fetch-Mode", "Sec-Fetch-Site", "Sec-Fetch-User",
+		},
+	},
+	"safari": {
+		Browser: "safari",
+		Version: "17.4.1",
+		OS:      OSAttributes{"Intel Mac OS X", "10_15_7", []string{}},
+		Accept: map[RequestType]string{
+			Navigation:        "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			FormSubmission:    "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			ImageRequest:      "image/webp,image/avif,image/jxl,image/heic,image/heic-sequence,video/*;q=0.8,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+			StylesheetRequest: "text/css,*/*;q=0.1",
+			ScriptRequest:     "*/*",
+			FetchRequest:      "*/*",
+		},
+		AcceptLanguage: "en-US,en;q=0.9",
+		AcceptEncoding: "gzip, deflate, br",
+		FetchMetadata:  true,
+		HeaderOrder: []string{
+			":method", ":scheme", ":path", ":authority",
+			"Host", "Content-Type", "Origin", "Accept", "Sec-Fetch-Site",
+			"Cookie", "Sec-Fetch-Dest", "Accept-Language", "Sec-Fetch-Mode",
+			"User-Agent", "Referer", "Content-Length", "Accept-Encoding",
+			"Connection",
+		},
+	},
+}
+
+// ChromeProfile returns the profile for the Chrome desktop browser.
+func ChromeProfile() *Profile {
+	return NewProfile("chrome")
+}
+
+// ChromeMobileProfile returns the profile for the Chrome browser on Android.
+func ChromeMobileProfile() *Profile {
+	return NewProfile("chromemobile")
+}
+
+// FirefoxProfile returns the profile for the Firefox desktop browser.
+func FirefoxProfile() *Profile {
+	return NewProfile("firefox")
+}
+
+// SafariProfile returns the profile for the Safari desktop browser.
+func SafariProfile() *Profile {
+	return NewProfile("safari")
+}
+
+// NewProfile returns a copy of the profile with the given name in Profiles,
+// or nil when there is no such profile.
+func NewProfile(name string) *Profile {
+	p, ok := Profiles[strings.ToLower(name)]
+	if !ok {
+		return nil
+	}
+	accept := make(map[RequestType]string, len(p.Accept))
+	for rt, v := range p.Accept {
+		accept[rt] = v
+	}
+	p.Accept = accept
+	p.HeaderOrder = append([]string(nil), p.HeaderOrder...)
+	return &p
+}
+
+// UserAgent returns the user agent string of the profile.
+func (p *Profile) UserAgent() string {
+	return createFromDetails(p.Browser, p.Version, p.OS.OSName, p.OS.OSVersion, p.OS.Comments)
+}
+
+// Headers returns the headers the browser sends with the given kind of
+// request, including the User-Agent header. The site is the Sec-Fetch-Site
+// value, one of SiteNone, SiteSameOrigin, SiteSameSite or SiteCrossSite.
+//
+// The Referer, Origin, Cookie and Content-Type headers depend on the request
+// and are left to the caller.
+func (p *Profile) Headers(rt RequestType, site string) http.Header {
+	h := make(http.Header)
+	navigation := rt == Navigation || rt == FormSubmission
+	if rt == FormSubmission {
+		h.Set("Cache-Control", "max-age=0")
+	}
+	if p.Brands != "" {
+		h["sec-ch-ua"] = []string{p.Brands}
+		h["sec-ch-ua-mobile"] = []string{"?0"}
+		if p.Mobile {
+			h["sec-ch-ua-mobile"] = []string{"?1"}
+		}
+		h["sec-ch-ua-platform"] = []string{`"` + p.Platform + `"`}
+	}
+	if navigation && p.UpgradeInsecureRequests {
+		h.Set("Upgrade-Insecure-Requests", "1")
+	}
+	h.Set("User-Agent", p.UserAgent())
+	if accept, ok := p.Accept[rt]; ok {
+		h.Set("Accept", accept)
+	}
+	if p.FetchMetadata {
+		h.Set("Sec-Fetch-Site", site)
+		if navigation {
+			h.Set("Sec-Fetch-Mode", "navigate")
+			h.Set("Sec-Fetch-User", "?1")
+		} else if rt == FetchRequest {
+			h.Set("Sec-Fetch-Mode", "cors")
+		} else {
+			h.Set("Sec-Fetch-Mode", "no-cors")
+		}
+		h.Set("Sec-Fetch-Dest", fetchDest(rt))
+	}
+	if p.AcceptEncoding != "" {
+		h.Set("Accept-Encoding", p.AcceptEncoding)
+	}
+	if p.AcceptLanguage != "" {
+		h.Set("Accept-Language", p.AcceptLanguage)
+	}
+	return h
+}
+
+// fetchDest returns the Sec-Fetch-Dest value for the kind of request.
+func fetchDest(rt RequestType) string {
+	switch rt {
+	case ImageRequest:
+		return "image"
+	case StylesheetRequest:
+		return "style"
+	case ScriptRequest:
+		return "script"
+	case FetchRequest:
+		return "empty"
+	}
+	return "document"
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"github.com/headzoo/ut"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	ut.Run(t)
+	ut.AssertNil(NewProfile("lynx"))
+
+	p := ChromeProfile()
+	ut.AssertEquals("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", p.UserAgent())
+	h := p.Headers(Navigation, SiteNone)
+	ut.AssertEquals(p.UserAgent(), h.Get("User-Agent"))
+	ut.AssertEquals(`"Windows"`, h["sec-ch-ua-platform"][0])
+	ut.AssertEquals("?0", h["sec-ch-ua-mobile"][0])
+	ut.AssertEquals("1", h.Get("Upgrade-Insecure-Requests"))
+	ut.AssertEquals("none", h.Get("Sec-Fetch-Site"))
+	ut.AssertEquals("navigate", h.Get("Sec-Fetch-Mode"))
+	ut.AssertEquals("?1", h.Get("Sec-Fetch-User"))
+	ut.AssertEquals("document", h.Get("Sec-Fetch-Dest"))
+	ut.AssertEquals("", h.Get("Cache-Control"))
+
+	h = p.Headers(FormSubmission, SiteSameOrigin)
+	ut.AssertEquals("max-age=0", h.Get("Cache-Control"))
+	ut.AssertEquals("same-origin", h.Get("Sec-Fetch-Site"))
+
+	h = p.Headers(ImageRequest, SiteCrossSite)
+	ut.AssertEquals("image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8", h.Get("Accept"))
+	ut.AssertEquals("no-cors", h.Get("Sec-Fetch-Mode"))
+	ut.AssertEquals("image", h.Get("Sec-Fetch-Dest"))
+	ut.AssertEquals("", h.Get("Sec-Fetch-User"))
+	ut.AssertEquals("", h.Get("Upgrade-Insecure-Requests"))
+
+	// Profiles are copies.
+	p.Accept[Navigation] = "*/*"
+	ut.AssertFalse(ChromeProfile().Accept[Navigation] == "*/*")
+
+	p = ChromeMobileProfile()
+	ut.AssertEquals("Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36", p.UserAgent())
+	h = p.Headers(Navigation, SiteNone)
+	ut.AssertEquals("?1", h["sec-ch-ua-mobile"][0])
+	ut.AssertEquals(`"Android"`, h["sec-ch-ua-platform"][0])
+
+	p = FirefoxProfile()
+	ut.AssertEquals("Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0", p.UserAgent())
+	h = p.Headers(StylesheetRequest, SiteSameOrigin)
+	ut.AssertEquals(0, len(h["sec-ch-ua"]))
+	ut.AssertEquals("text/css,*/*;q=0.1", h.Get("Accept"))
+	ut.AssertEquals("style", h.Get("Sec-Fetch-Dest"))
+
+	p = SafariProfile()
+	ut.AssertEquals("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15", p.UserAgent())
+	ut.AssertEquals("", p.Headers(Navigation, SiteNone).Get("Upgrade-Insecure-Requests"))
+}
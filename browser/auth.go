@@ -0,0 +1,431 @@
+package browser
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Credentials authenticate the requests sent to a host.
+//
+// The username and password answer Basic and Digest challenges, and the
+// token is sent as a Bearer token with every request. When OAuth2 is set,
+// the Bearer token is the access token it gets instead.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+	OAuth2   *OAuth2
+}
+
+// SetCredentials sets the credentials used for the hosts matching the
+// pattern, or removes them when creds is nil.
+//
+// The pattern is a host name such as "example.com", which matches any port,
+// a host and port such as "example.com:8080", or a wildcard such as
+// "*.example.com", which matches the subdomains of example.com.
+func (bow *Browser) SetCredentials(pattern string, creds *Credentials) {
+	if bow.credentials == nil {
+		bow.credentials = newCredentialStore()
+	}
+	bow.credentials.set(pattern, creds)
+	bow.applyTransport()
+}
+
+// credentialStore holds the credentials of a browser, and the last
+// challenge of each host.
+type credentialStore struct {
+	mu         sync.Mutex
+	creds      map[string]*Credentials
+	challenges map[string]*challenge
+}
+
+// newCredentialStore creates and returns a new *credentialStore type.
+func newCredentialStore() *credentialStore {
+	return &credentialStore{
+		creds:      make(map[string]*Credentials),
+		challenges: make(map[string]*challenge),
+	}
+}
+
+// set sets the credentials of the pattern.
+func (s *credentialStore) set(pattern string, creds *Credentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pattern = strings.ToLower(pattern)
+	if creds == nil {
+		delete(s.creds, pattern)
+	} else {
+		s.creds[pattern] = creds
+	}
+	s.challenges = make(map[string]*challenge)
+}
+
+// match returns the credentials for the URL.
+func (s *credentialStore) match(u *url.URL) *Credentials {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	patterns := make([]string, 0, len(s.creds))
+	for pattern := range s.creds {
+		patterns = append(patterns, pattern)
+	}
+	return s.creds[matchHost(u, patterns)]
+}
+
+// matchHost returns the pattern matching the host of the URL, or an empty
+// string when none does. A pattern with a port is preferred over a host
+// name, and a host name over a wildcard. The patterns are lower case.
+func matchHost(u *url.URL, patterns []string) string {
+	host := strings.ToLower(u.Host)
+	name := strings.ToLower(u.Hostname())
+	best := ""
+	for _, pattern := range patterns {
+		switch {
+		case pattern == host:
+			return pattern
+		case pattern == name:
+			best = pattern
+		case best != name && strings.HasPrefix(pattern, "*.") &&
+			strings.HasSuffix(name, pattern[1:]) && len(pattern) > len(best):
+			best = pattern
+		}
+	}
+	return best
+}
+
+// authorization returns the Authorization header for the request, which
+// answers the last challenge of the host. Returns the Bearer token when the
+// host has not sent a challenge. The body is only read for a Digest
+// challenge asking for the integrity of the body.
+func (s *credentialStore) authorization(req *http.Request, creds *Credentials, body func() []byte) string {
+	s.mu.Lock()
+	ch := s.challenges[req.URL.Host]
+	var nc uint32
+	if ch != nil {
+		ch.count++
+		nc = ch.count
+	}
+	s.mu.Unlock()
+
+	switch {
+	case ch != nil && ch.scheme == "digest":
+		return digestAuthorization(ch, nc, req, creds, body)
+	case ch != nil && ch.scheme == "basic":
+		auth := creds.Username + ":" + creds.Password
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+	case creds.Token != "":
+		return "Bearer " + creds.Token
+	}
+	return ""
+}
+
+// setChallenge remembers the challenge sent by the host.
+func (s *credentialStore) setChallenge(host string, ch *challenge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[host] = ch
+}
+
+// challenge is an authentication challenge from a WWW-Authenticate header.
+type challenge struct {
+	scheme string
+	params map[string]string
+
+	// count is the number of requests sent with the nonce of the challenge.
+	count uint32
+}
+
+// authTransport is an http.RoundTripper adding the credentials of the host
+// to each request, and answering the Basic and Digest challenges of the
+// server. The Authorization header is only added to the request sent to the
+// host, so it does not follow redirects to other hosts.
+type authTransport struct {
+	base  http.RoundTripper
+	store *credentialStore
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	creds := t.store.match(req.URL)
+	if creds == nil {
+		return t.base.RoundTrip(req)
+	}
+	// A Bearer token is never sent again, so the body is not kept.
+	if creds.Username == "" && creds.OAuth2 == nil {
+		r := req.Clone(req.Context())
+		if auth := t.store.authorization(req, creds, nil); auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		return t.base.RoundTrip(r)
+	}
+
+	req, err := replayable(req)
+	if err != nil {
+		return nil, err
+	}
+	sent := false
+	send := func(auth string) (*http.Response, error) {
+		r := req.Clone(req.Context())
+		if sent && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		sent = true
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		return t.base.RoundTrip(r)
+	}
+	body := func() []byte {
+		if req.GetBody == nil {
+			return nil
+		}
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		defer rc.Close()
+		b, _ := ioutil.ReadAll(rc)
+		return b
+	}
+
+	if creds.OAuth2 != nil {
+		return t.roundTripOAuth2(req, creds.OAuth2, send)
+	}
+
+	auth := t.store.authorization(req, creds, body)
+	resp, err := send(auth)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || creds.Username == "" {
+		return resp, err
+	}
+	ch := parseChallenge(resp.Header.Values("Www-Authenticate"))
+	if ch == nil {
+		return resp, nil
+	}
+	// Wrong credentials are not sent again, unless the nonce they were sent
+	// with has expired.
+	if strings.HasPrefix(auth, "Basic ") || strings.HasPrefix(auth, "Digest ") {
+		if !strings.EqualFold(ch.params["stale"], "true") {
+			return resp, nil
+		}
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	t.store.setChallenge(req.URL.Host, ch)
+	return send(t.store.authorization(req, creds, body))
+}
+
+// replayable returns the request with a body which can be read again with
+// GetBody, for sending the request again after a challenge. The body is only
+// read into memory when GetBody is not set.
+func replayable(req *http.Request) (*http.Request, error) {
+	if req.GetBody != nil || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return r, nil
+}
+
+// roundTripOAuth2 sends the request with an OAuth2 access token, and sends
+// it again with a new token when the server rejects the token.
+func (t *authTransport) roundTripOAuth2(req *http.Request, o *OAuth2, send func(string) (*http.Response, error)) (*http.Response, error) {
+	token, err := o.accessToken(req, t.base)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := send("Bearer " + token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	o.invalidate(token)
+	token, err = o.accessToken(req, t.base)
+	if err != nil {
+		return resp, nil
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return send("Bearer " + token)
+}
+
+// parseChallenge returns the strongest Basic or Digest challenge in the
+// WWW-Authenticate headers, or nil when there is none.
+func parseChallenge(headers []string) *challenge {
+	var best *challenge
+	for _, h := range headers {
+		for _, ch := range parseChallenges(h) {
+			switch {
+			case ch.scheme == "digest" && !digestSupported(ch):
+			case ch.scheme == "digest":
+				return ch
+			case ch.scheme == "basic" && best == nil:
+				best = ch
+			}
+		}
+	}
+	return best
+}
+
+// parseChallenges parses the challenges of a WWW-Authenticate header, e.g.
+// `Digest realm="x", nonce="y", Basic realm="x"`.
+func parseChallenges(h string) []*challenge {
+	var challenges []*challenge
+	var ch *challenge
+	for h = strings.TrimSpace(h); h != ""; h = strings.TrimLeft(h, " \t,") {
+		token := h
+		if i := strings.IndexAny(h, " \t,="); i >= 0 {
+			token = h[:i]
+		}
+		h = strings.TrimLeft(h[len(token):], " \t")
+		if !strings.HasPrefix(h, "=") {
+			ch = &challenge{scheme: strings.ToLower(token), params: make(map[string]string)}
+			challenges = append(challenges, ch)
+			continue
+		}
+		h = strings.TrimLeft(h[1:], " \t")
+		var value string
+		if strings.HasPrefix(h, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(h) && h[i] != '"'; i++ {
+				if h[i] == '\\' && i+1 < len(h) {
+					i++
+				}
+				b.WriteByte(h[i])
+			}
+			value = b.String()
+			if i < len(h) {
+				i++
+			}
+			h = h[i:]
+		} else {
+			i := strings.IndexAny(h, " \t,")
+			if i < 0 {
+				i = len(h)
+			}
+			value, h = h[:i], h[i:]
+		}
+		if ch != nil {
+			ch.params[strings.ToLower(token)] = value
+		}
+	}
+	return challenges
+}
+
+// digestSupported returns true when the algorithm and qop of the Digest
+// challenge are supported.
+func digestSupported(ch *challenge) bool {
+	if digestHash(ch.params["algorithm"]) == nil {
+		return false
+	}
+	qop, ok := ch.params["qop"]
+	return !ok || digestQop(qop) != ""
+}
+
+// digestQop picks the quality of protection from the qop parameter of a
+// challenge, preferring "auth".
+func digestQop(qop string) string {
+	picked := ""
+	for _, q := range strings.Split(qop, ",") {
+		switch strings.TrimSpace(q) {
+		case "auth":
+			return "auth"
+		case "auth-int":
+			picked = "auth-int"
+		}
+	}
+	return picked
+}
+
+// digestHash returns the hash function of the Digest algorithm, or nil when
+// the algorithm is not supported.
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")) {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+// digestAuthorization returns the Authorization header answering the
+// Digest challenge, as described in RFC 7616.
+func digestAuthorization(ch *challenge, nc uint32, req *http.Request, creds *Credentials, body func() []byte) string {
+	newHash := digestHash(ch.params["algorithm"])
+	h := func(s string) string {
+		hh := newHash()
+		io.WriteString(hh, s)
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+	realm, nonce := ch.params["realm"], ch.params["nonce"]
+	uri := req.URL.RequestURI()
+	cnonce := newCnonce()
+
+	ha1 := h(creds.Username + ":" + realm + ":" + creds.Password)
+	if strings.HasSuffix(strings.ToLower(ch.params["algorithm"]), "-sess") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+	qop := ""
+	if q, ok := ch.params["qop"]; ok {
+		qop = digestQop(q)
+	}
+	if qop == "auth-int" {
+		var b []byte
+		if body != nil {
+			b = body()
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + h(string(b)))
+	}
+
+	ncs := fmt.Sprintf("%08x", nc)
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ncs + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	auth := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q`, creds.Username, realm, nonce, uri)
+	if alg, ok := ch.params["algorithm"]; ok {
+		auth += ", algorithm=" + alg
+	}
+	auth += fmt.Sprintf(`, response=%q`, response)
+	if opaque, ok := ch.params["opaque"]; ok {
+		auth += fmt.Sprintf(`, opaque=%q`, opaque)
+	}
+	if qop != "" {
+		auth += fmt.Sprintf(`, qop=%s, nc=%s, cnonce=%q`, qop, ncs, cnonce)
+	}
+	return auth
+}
+
+// newCnonce returns a random client nonce.
+func newCnonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
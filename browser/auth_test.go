@@ -0,0 +1,190 @@
+package browser
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestCredentialsDigest(t *testing.T) {
+	ut.Run(t)
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	var counts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		ch := parseChallenge([]string{auth})
+		if ch == nil || ch.scheme != "digest" || ch.params["nonce"] != "abc" {
+			w.Header().Add("WWW-Authenticate", `Basic realm="test"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="test", qop="auth,auth-int", nonce="abc", opaque="xyz"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := ch.params
+		ha1 := h("joe:test:secret")
+		ha2 := h(r.Method + ":" + p["uri"])
+		want := h(ha1 + ":abc:" + p["nc"] + ":" + p["cnonce"] + ":" + p["qop"] + ":" + ha2)
+		if p["response"] != want || p["opaque"] != "xyz" || p["uri"] != r.URL.RequestURI() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		counts = append(counts, p["nc"])
+		fmt.Fprint(w, "<html><title>Welcome</title></html>")
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	u, _ := url.Parse(ts.URL)
+	bow.SetCredentials(u.Hostname(), &Credentials{Username: "joe", Password: "secret"})
+	err := bow.Open(ts.URL + "/private?page=1")
+	ut.AssertNil(err)
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals("Welcome", bow.Title())
+
+	err = bow.Open(ts.URL + "/other")
+	ut.AssertNil(err)
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals([]string{"00000001", "00000002"}, counts)
+
+	bow.SetCredentials(u.Hostname(), &Credentials{Username: "joe", Password: "wrong"})
+	err = bow.Open(ts.URL + "/private")
+	ut.AssertNil(err)
+	ut.AssertEquals(401, bow.StatusCode())
+}
+
+func TestCredentialsBasic(t *testing.T) {
+	ut.Run(t)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><title>%s</title></html>", r.Header.Get("Authorization"))
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "joe" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, other.URL+"/", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "<html><title>Welcome</title></html>")
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	u, _ := url.Parse(ts.URL)
+	u.User = url.UserPassword("joe", "secret")
+	err := bow.Open(u.String())
+	ut.AssertNil(err)
+	ut.AssertEquals("Welcome", bow.Title())
+	ut.AssertEquals("", bow.Url().User.String())
+
+	// The credentials are not sent to the host of the redirect.
+	err = bow.Open(ts.URL + "/away")
+	ut.AssertNil(err)
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals("", bow.Title())
+}
+
+func TestCredentialsBearer(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><title>%s</title></html>", r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	bow.SetCredentials("*.example.com", &Credentials{Token: "wrong"})
+	bow.SetCredentials(ts.Listener.Addr().String(), &Credentials{Token: "t0ken"})
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("Bearer t0ken", bow.Title())
+
+	bow.SetCredentials(ts.Listener.Addr().String(), nil)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("", bow.Title())
+
+	store := newCredentialStore()
+	store.set("*.example.com", &Credentials{Token: "a"})
+	store.set("*.api.example.com", &Credentials{Token: "b"})
+	u, _ := url.Parse("https://v1.api.example.com/")
+	ut.AssertEquals("b", store.match(u).Token)
+	u, _ = url.Parse("https://example.com/")
+	ut.AssertNil(store.match(u))
+}
+
+// bodyRecorder is an http.RoundTripper recording the bodies of the requests,
+// and answering the first request with a Basic challenge.
+type bodyRecorder struct {
+	bodies []io.Reader
+	data   []string
+}
+
+func (rt *bodyRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.bodies = append(rt.bodies, req.Body)
+	b, _ := ioutil.ReadAll(req.Body)
+	rt.data = append(rt.data, string(b))
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header), Body: http.NoBody, Request: req}
+	if len(rt.bodies) == 1 {
+		resp.StatusCode = http.StatusUnauthorized
+		resp.Header.Set("WWW-Authenticate", `Basic realm="test"`)
+	}
+	return resp, nil
+}
+
+func TestCredentialsBody(t *testing.T) {
+	ut.Run(t)
+	store := newCredentialStore()
+	store.set("basic.example.com", &Credentials{Username: "joe", Password: "secret"})
+	store.set("token.example.com", &Credentials{Token: "t0ken"})
+
+	// The body is not buffered for hosts without credentials, or with a token.
+	for _, u := range []string{"http://other.example.com/", "http://token.example.com/"} {
+		rt := &bodyRecorder{}
+		body := ioutil.NopCloser(strings.NewReader("a=1"))
+		req, _ := http.NewRequest("POST", u, body)
+		tr := &authTransport{base: rt, store: store}
+		tr.RoundTrip(req)
+		ut.AssertEquals(1, len(rt.bodies))
+		ut.AssertTrue(rt.bodies[0] == body)
+	}
+
+	// The body is sent again after a challenge, using GetBody when it is set.
+	rt := &bodyRecorder{}
+	req, _ := http.NewRequest("POST", "http://basic.example.com/", strings.NewReader("a=1"))
+	getBody := req.GetBody
+	calls := 0
+	req.GetBody = func() (io.ReadCloser, error) {
+		calls++
+		return getBody()
+	}
+	resp, err := (&authTransport{base: rt, store: store}).RoundTrip(req)
+	ut.AssertNil(err)
+	ut.AssertEquals(200, resp.StatusCode)
+	ut.AssertEquals([]string{"a=1", "a=1"}, rt.data)
+	ut.AssertEquals(1, calls)
+
+	// Bodies which cannot be read again are buffered. Setting the credentials
+	// again forgets the challenge.
+	store.set("basic.example.com", &Credentials{Username: "joe", Password: "secret"})
+	rt = &bodyRecorder{}
+	req, _ = http.NewRequest("POST", "http://basic.example.com/", ioutil.NopCloser(strings.NewReader("b=2")))
+	resp, err = (&authTransport{base: rt, store: store}).RoundTrip(req)
+	ut.AssertNil(err)
+	ut.AssertEquals(200, resp.StatusCode)
+	ut.AssertEquals([]string{"b=2", "b=2"}, rt.data)
+}
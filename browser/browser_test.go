@@ -1,12 +1,18 @@
 package browser
 
 import (
+	"context"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/dataxpe/surf/agent"
+	"github.com/dataxpe/surf/errors"
 	"github.com/dataxpe/surf/jar"
 )
 
@@ -88,3 +94,237 @@ func TestRedirect(t *testing.T) {
 		return
 	}
 }
+
+func TestNetworkErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	b := newDefaultTestBrowser()
+	b.SetTimeout(50 * time.Millisecond)
+	err := b.Open(ts.URL)
+	if !errors.Is(err, errors.ErrTimeout) || !errors.Is(err, errors.ErrRequest) {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+	if b.StatusCode() != 0 {
+		t.Errorf("Expected no status code, got %d", b.StatusCode())
+	}
+	if b.GetState().Err != err {
+		t.Errorf("Expected the failed attempt to be recorded in the state")
+	}
+
+	// Find a port nothing listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	b = newDefaultTestBrowser()
+	err = b.Open("http://" + addr)
+	if !errors.Is(err, errors.ErrConnectionRefused) {
+		t.Fatalf("Expected a connection refused error, got %v", err)
+	}
+	var rerr errors.ConnectionRefused
+	if !errors.As(err, &rerr) || rerr.Method != "GET" || rerr.URL != "http://"+addr {
+		t.Errorf("Expected the request to be recorded in the error, got %#v", rerr)
+	}
+
+	b = newDefaultTestBrowser()
+	b.SetTimeout(50 * time.Millisecond)
+	b.SetAttribute(SyntheticErrorPages, true)
+	if err := b.Open(ts.URL); err != nil {
+		t.Errorf("Expected timeouts to be hidden, got %v", err)
+	}
+	if b.StatusCode() != 503 {
+		t.Errorf("Expected a synthetic 503, got %d", b.StatusCode())
+	}
+}
+
+func TestParseRefresh(t *testing.T) {
+	tests := []struct {
+		content string
+		dur     time.Duration
+		url     string
+		ok      bool
+	}{
+		{"5", 5 * time.Second, "", true},
+		{"0; url=/next", 0, "/next", true},
+		{"3;URL='http://example.com/a?b=c'", 3 * time.Second, "http://example.com/a?b=c", true},
+		{"1.5, url = \"/quoted\"", 1500 * time.Millisecond, "/quoted", true},
+		{"0;/bare", 0, "/bare", true},
+		{"soon", 0, "", false},
+	}
+	for _, test := range tests {
+		dur, u, ok := parseRefresh(test.content)
+		if dur != test.dur || u != test.url || ok != test.ok {
+			t.Errorf("parseRefresh(%q) = %v, %q, %v", test.content, dur, u, ok)
+		}
+	}
+}
+
+func TestMetaRefresh(t *testing.T) {
+	var referer string
+	loops := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			io.WriteString(w, `<html><head><meta http-equiv="refresh" content="0; url=/next"></head></html>`)
+		case "/delayed":
+			io.WriteString(w, `<html><head><meta http-equiv="refresh" content="5; url=/next"></head></html>`)
+		case "/short":
+			io.WriteString(w, `<html><head><meta http-equiv="refresh" content="0.1; url=/next"></head></html>`)
+		case "/loop":
+			loops++
+			io.WriteString(w, `<html><head><meta http-equiv="refresh" content="0"></head></html>`)
+		case "/next":
+			referer = r.Referer()
+			io.WriteString(w, `<html><head><title>Next</title></head></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	b := newDefaultTestBrowser()
+	if err := b.Open(ts.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	if b.Title() != "Next" || referer != ts.URL+"/" {
+		t.Errorf("Expected refresh to /next with a referer, got %s from %q", b.Url(), referer)
+	}
+
+	b = newDefaultTestBrowser()
+	b.SetMaxRefreshDelay(time.Second)
+	b.Open(ts.URL + "/delayed")
+	if b.Url().Path != "/delayed" {
+		t.Errorf("Expected refresh over the maximum delay to be ignored, got %s", b.Url())
+	}
+
+	b = newDefaultTestBrowser()
+	start := time.Now()
+	b.Open(ts.URL + "/delayed")
+	if b.Url().Path != "/next" || time.Since(start) > time.Second {
+		t.Errorf("Expected the refresh to be followed at once, got %s", b.Url())
+	}
+
+	b = newDefaultTestBrowser()
+	b.SetWaitRefreshDelay(true)
+	start = time.Now()
+	b.Open(ts.URL + "/short")
+	if b.Url().Path != "/next" || time.Since(start) < 100*time.Millisecond {
+		t.Errorf("Expected the refresh to be followed after its delay, got %s", b.Url())
+	}
+
+	// The wait ends when the context of the request is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := b.buildRequest("GET", ts.URL+"/delayed", nil, nil)
+	start = time.Now()
+	err := b.httpRequest(req.WithContext(ctx))
+	if err != context.DeadlineExceeded || time.Since(start) > time.Second || b.Url().Path != "/delayed" {
+		t.Errorf("Expected the wait to be cancelled, got %v at %s", err, b.Url())
+	}
+
+	b = newDefaultTestBrowser()
+	b.SetMaxRefreshes(2)
+	b.Open(ts.URL + "/loop")
+	if loops != 3 {
+		t.Errorf("Expected 2 refreshes, got %d", loops-1)
+	}
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html><head><title>"+r.URL.Path+"</title></head></html>")
+	}))
+	defer ts.Close()
+
+	b := newDefaultTestBrowser()
+	b.SetHistoryCapacity(10)
+	for _, p := range []string{"/step1", "/step2", "/step3"} {
+		if err := b.Open(ts.URL + p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !b.Back() || !b.Back() || b.Title() != "/step1" {
+		t.Fatalf("Expected to go back to /step1, got %s", b.Title())
+	}
+	if b.Back() {
+		t.Errorf("Expected no page before /step1")
+	}
+	if !b.Forward() || b.Title() != "/step2" {
+		t.Errorf("Expected to go forward to /step2, got %s", b.Title())
+	}
+	if !b.Go(1) || b.Title() != "/step3" {
+		t.Errorf("Expected to go to /step3, got %s", b.Title())
+	}
+	if b.Go(1) {
+		t.Errorf("Expected no page after /step3")
+	}
+
+	b.Go(-2)
+	b.Open(ts.URL + "/other")
+	entries := b.HistoryEntries()
+	if len(entries) != 2 || entries[1].Title != "/other" || entries[1].StatusCode != 200 {
+		t.Errorf("Expected the forward pages to be dropped, got %d entries", len(entries))
+	}
+}
+
+func TestBadContentEncoding(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			io.WriteString(w, "not gzip")
+			return
+		}
+		io.WriteString(w, "<html><head><title>Home</title></head></html>")
+	}))
+	defer ts.Close()
+
+	b := newDefaultTestBrowser()
+	b.AddRequestHeader("Accept-Encoding", "gzip")
+	if err := b.Open(ts.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	err := b.Open(ts.URL + "/gzip")
+	if !errors.Is(err, errors.ErrRequest) {
+		t.Errorf("Expected a request error, got %v", err)
+	}
+	if b.Url().Path != "/gzip" || b.StatusCode() != 200 || b.Title() != "" {
+		t.Errorf("Expected the state of the failed page, got %s %d %q", b.Url(), b.StatusCode(), b.Title())
+	}
+}
+
+func TestReload(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, r.Method+" "+string(b))
+		io.WriteString(w, "<html><head><title>"+r.URL.Path+"</title></head></html>")
+	}))
+	defer ts.Close()
+
+	b := newDefaultTestBrowser()
+	b.SetHistoryJar(jar.NewCompactHistory())
+	b.SetHistoryCapacity(10)
+	if err := b.PostForm(ts.URL+"/post", url.Values{"a": {"1"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[1] != "POST a=1" {
+		t.Errorf("Expected the POST to be sent again with its body, got %q", bodies)
+	}
+
+	// The body of a page rebuilt from a snapshot is not kept.
+	b.Open(ts.URL + "/other")
+	if !b.Back() || b.Title() != "/post" {
+		t.Fatalf("Expected to go back to /post, got %s", b.Title())
+	}
+	err := b.Reload()
+	if !errors.Is(err, errors.ErrPageNotLoaded) || len(bodies) != 3 {
+		t.Errorf("Expected the POST not to be sent again, got %v and %q", err, bodies)
+	}
+}
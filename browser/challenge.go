@@ -0,0 +1,54 @@
+package browser
+
+import (
+	"net/http"
+)
+
+// ChallengeSolver solves the challenge pages which anti-bot services send
+// instead of the requested page.
+type ChallengeSolver interface {
+	// Detect returns true when the response is a challenge the solver
+	// solves. The response body must not be read.
+	Detect(resp *http.Response) bool
+
+	// Solve solves the challenge in the response, and loads the requested
+	// page in the browser, e.g. by sending the answer with bow.Post. The
+	// response body is a copy, which the solver does not need to close.
+	// Errors other than errors.Challenge errors load the challenge page, and
+	// are returned as the cause of a Challenge error.
+	Solve(bow *Browser, resp *http.Response) error
+}
+
+// SetChallengeSolvers sets the solvers of the challenges the browser
+// receives. The first solver detecting a challenge solves it. No challenge
+// is solved when no solvers are given. Browsers solve the Cloudflare
+// challenge until solvers are set.
+func (bow *Browser) SetChallengeSolvers(solvers ...ChallengeSolver) {
+	bow.challengeSolvers = append([]ChallengeSolver{}, solvers...)
+}
+
+// AddChallengeSolver adds a solver, which is tried after the solvers which
+// are already set.
+func (bow *Browser) AddChallengeSolver(solver ChallengeSolver) {
+	bow.challengeSolvers = append(bow.ChallengeSolvers(), solver)
+}
+
+// ChallengeSolvers returns the solvers of the challenges the browser
+// receives.
+func (bow *Browser) ChallengeSolvers() []ChallengeSolver {
+	if bow.challengeSolvers == nil {
+		return []ChallengeSolver{&CloudflareSolver{}}
+	}
+	return append([]ChallengeSolver{}, bow.challengeSolvers...)
+}
+
+// challengeSolver returns the solver of the challenge in the response, or
+// nil when the response is not a challenge.
+func (bow *Browser) challengeSolver(resp *http.Response) ChallengeSolver {
+	for _, s := range bow.ChallengeSolvers() {
+		if s.Detect(resp) {
+			return s
+		}
+	}
+	return nil
+}
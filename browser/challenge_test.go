@@ -0,0 +1,93 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dataxpe/surf/errors"
+	"github.com/headzoo/ut"
+)
+
+// testSolver solves the challenges of the test server by opening the answer
+// URL given in a header.
+type testSolver struct {
+	solved int
+	fail   bool
+}
+
+func (s *testSolver) Detect(resp *http.Response) bool {
+	return resp.StatusCode == 429 && resp.Header.Get("X-Answer") != ""
+}
+
+func (s *testSolver) Solve(bow *Browser, resp *http.Response) error {
+	s.solved++
+	if s.fail {
+		return fmt.Errorf("no answer")
+	}
+	return bow.Open(resp.Request.URL.Scheme + "://" + resp.Request.URL.Host + resp.Header.Get("X-Answer"))
+}
+
+func TestChallengeSolvers(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/home":
+			fmt.Fprint(w, "<html><head><title>Home</title></head></html>")
+		case "/answer":
+			http.SetCookie(w, &http.Cookie{Name: "passed", Value: "1"})
+			fmt.Fprint(w, "<html><head><title>Answered</title></head></html>")
+		case "/loop":
+			w.Header().Set("X-Answer", "/loop")
+			w.WriteHeader(429)
+		default:
+			if _, err := r.Cookie("passed"); err == nil {
+				fmt.Fprint(w, "<html><head><title>Page</title></head></html>")
+				return
+			}
+			w.Header().Set("X-Answer", "/answer")
+			w.WriteHeader(429)
+			fmt.Fprint(w, "<html><head><title>Challenge</title></head></html>")
+		}
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	ut.AssertEquals(1, len(bow.ChallengeSolvers()))
+
+	// No solver detects the challenge.
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertEquals(429, bow.StatusCode())
+	ut.AssertEquals("Challenge", bow.Title())
+
+	solver := &testSolver{}
+	bow.AddChallengeSolver(solver)
+	ut.AssertEquals(2, len(bow.ChallengeSolvers()))
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertEquals(1, solver.solved)
+	ut.AssertEquals("Answered", bow.Title())
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertEquals("Page", bow.Title())
+
+	// The retries are limited.
+	err := bow.Open(ts.URL + "/loop")
+	ut.AssertTrue(errors.Is(err, errors.ErrChallenge))
+
+	// Errors of the solver load the challenge page.
+	bow = newDefaultTestBrowser()
+	bow.SetChallengeSolvers(&testSolver{fail: true})
+	ut.AssertNil(bow.Open(ts.URL + "/home"))
+	ut.AssertEquals("Home", bow.Title())
+	err = bow.Open(ts.URL + "/page")
+	ut.AssertTrue(errors.Is(err, errors.ErrChallenge))
+	ut.AssertEquals(429, bow.StatusCode())
+	ut.AssertEquals("/page", bow.Url().Path)
+	ut.AssertEquals("Challenge", bow.Title())
+
+	// No challenge is solved without solvers.
+	bow.SetChallengeSolvers()
+	ut.AssertEquals(0, len(bow.ChallengeSolvers()))
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertEquals(429, bow.StatusCode())
+}
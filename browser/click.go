@@ -0,0 +1,222 @@
+package browser
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Diggernaut/goquery"
+	"github.com/dataxpe/surf/errors"
+)
+
+// ClickLink follows the link whose text is the given text. Spaces around
+// and inside the text are ignored. Links without text match their title
+// attribute or the alt text of their image, and areas of image maps match
+// their alt text.
+//
+// An errors.Ambiguous error listing the links is returned when links
+// pointing to different pages match.
+func (bow *Browser) ClickLink(text string) error {
+	text = normalizeSpace(text)
+	return bow.followLinks(bow.Find("a[href],area[href]"), func(s *goquery.Selection) bool {
+		return linkText(s) == text
+	}, fmt.Sprintf("the text '%s'", text))
+}
+
+// ClickLinkMatch follows the link whose text matches the regular
+// expression. Links are matched the same way as ClickLink matches them.
+func (bow *Browser) ClickLinkMatch(re *regexp.Regexp) error {
+	return bow.followLinks(bow.Find("a[href],area[href]"), func(s *goquery.Selection) bool {
+		return re.MatchString(linkText(s))
+	}, fmt.Sprintf("text matching '%s'", re))
+}
+
+// FollowRel follows the link with the given link type in its rel
+// attribute, e.g. "next" or "prev". Links, areas of image maps and link
+// elements in the head of the page are followed. The "prev" type also
+// matches "previous".
+//
+// An errors.Ambiguous error listing the links is returned when links
+// pointing to different pages match.
+func (bow *Browser) FollowRel(rel string) error {
+	rel = strings.ToLower(rel)
+	return bow.followLinks(bow.Find("a[href],area[href],link[href]"), func(s *goquery.Selection) bool {
+		for _, typ := range strings.Fields(strings.ToLower(attrOr(s, "rel", ""))) {
+			if typ == rel || rel == "prev" && typ == "previous" {
+				return true
+			}
+		}
+		return false
+	}, fmt.Sprintf("rel '%s'", rel))
+}
+
+// ClickButton submits the form which owns the submit button with the given
+// label. The label of a button is its text, the label of a submit input is
+// its value, and the label of an image input is its alt text.
+//
+// An errors.Ambiguous error listing the buttons is returned when more than
+// one button matches.
+func (bow *Browser) ClickButton(label string) error {
+	label = normalizeSpace(label)
+	var matches []*goquery.Selection
+	var candidates []string
+	bow.Find("button,input").Each(func(_ int, s *goquery.Selection) {
+		if isSubmitButton(s) && buttonLabel(s) == label {
+			matches = append(matches, s)
+			candidates = append(candidates, describeButton(s))
+		}
+	})
+	switch len(matches) {
+	case 0:
+		return errors.NewElementNotFound(
+			"No button found with the label '%s'.", label)
+	case 1:
+		return bow.clickButton(matches[0])
+	}
+	err := errors.NewAmbiguous(
+		"%d buttons found with the label '%s': %s.", len(matches), label, strings.Join(candidates, ", "))
+	err.SetCandidates(candidates)
+	return err
+}
+
+// clickElement clicks on the element, which is a link, an area of an image
+// map or a submit button.
+func (bow *Browser) clickElement(s *goquery.Selection, expr string) error {
+	switch {
+	case s.Is("a"), s.Is("area"):
+		href, err := bow.attrToResolvedUrl("href", s)
+		if err != nil {
+			return err
+		}
+		return bow.httpGET(href, bow.Url())
+	case isSubmitButton(s):
+		return bow.clickButton(s)
+	}
+	err := errors.NewElementNotFound(
+		"Expr '%s' must match a link, an area or a submit button.", expr)
+	err.Selector = expr
+	return err
+}
+
+// clickButton submits the form which owns the submit button.
+func (bow *Browser) clickButton(s *goquery.Selection) error {
+	owner := bow.ownerForm(s)
+	if owner == nil {
+		return errors.NewElementNotFound(
+			"The button %s is not in a form.", describeButton(s))
+	}
+	f := NewForm(bow, owner)
+	for _, c := range f.controls {
+		if c.node == nil || c.node.Get(0) != s.Get(0) {
+			continue
+		}
+		if c.disabled {
+			return errors.NewInvalidFormValue(
+				"The button %s is disabled.", describeButton(s))
+		}
+		return f.send(c)
+	}
+	return errors.NewElementNotFound(
+		"The button %s is not in a form.", describeButton(s))
+}
+
+// ownerForm returns the form which owns the control, which is the form
+// named by its form attribute, or else the form it is in.
+func (bow *Browser) ownerForm(s *goquery.Selection) *goquery.Selection {
+	if id, ok := s.Attr("form"); ok {
+		var owner *goquery.Selection
+		bow.Find("form").EachWithBreak(func(_ int, f *goquery.Selection) bool {
+			if attrOr(f, "id", "") == id {
+				owner = f
+				return false
+			}
+			return true
+		})
+		return owner
+	}
+	if f := s.Closest("form"); f.Length() > 0 {
+		return f
+	}
+	return nil
+}
+
+// followLinks follows the link of the elements accepted by match. Returns
+// an error listing the links when they point to different pages.
+func (bow *Browser) followLinks(sel *goquery.Selection, match func(*goquery.Selection) bool, what string) error {
+	var hrefs []*url.URL
+	var candidates []string
+	seen := make(map[string]bool)
+	sel.Each(func(_ int, s *goquery.Selection) {
+		if !match(s) {
+			return
+		}
+		href, err := bow.attrToResolvedUrl("href", s)
+		if err != nil || seen[href.String()] {
+			return
+		}
+		seen[href.String()] = true
+		hrefs = append(hrefs, href)
+		candidates = append(candidates, fmt.Sprintf("'%s' (%s)", linkText(s), href))
+	})
+	switch len(hrefs) {
+	case 0:
+		return errors.NewLinkNotFound("No link found with %s.", what)
+	case 1:
+		return bow.httpGET(hrefs[0], bow.Url())
+	}
+	err := errors.NewAmbiguous(
+		"%d links found with %s: %s.", len(hrefs), what, strings.Join(candidates, ", "))
+	err.SetCandidates(candidates)
+	return err
+}
+
+// isSubmitButton returns true when the element is a button, a submit input
+// or an image input which submits its form.
+func isSubmitButton(s *goquery.Selection) bool {
+	typ := strings.ToLower(attrOr(s, "type", ""))
+	if s.Is("button") {
+		return typ == "" || typ == "submit"
+	}
+	return s.Is("input") && (typ == "submit" || typ == "image")
+}
+
+// linkText returns the text of a link.
+func linkText(s *goquery.Selection) string {
+	if s.Is("area") {
+		return normalizeSpace(attrOr(s, "alt", ""))
+	}
+	if text := normalizeSpace(s.Text()); text != "" {
+		return text
+	}
+	if title := normalizeSpace(attrOr(s, "title", "")); title != "" {
+		return title
+	}
+	return normalizeSpace(attrOr(s.Find("img[alt]").First(), "alt", ""))
+}
+
+// buttonLabel returns the label of a submit button.
+func buttonLabel(s *goquery.Selection) string {
+	if s.Is("button") {
+		return normalizeSpace(s.Text())
+	}
+	if strings.EqualFold(attrOr(s, "type", ""), "image") {
+		return normalizeSpace(attrOr(s, "alt", attrOr(s, "value", "")))
+	}
+	return normalizeSpace(attrOr(s, "value", "Submit"))
+}
+
+// describeButton describes a submit button in error messages.
+func describeButton(s *goquery.Selection) string {
+	desc := fmt.Sprintf("'%s' (%s", buttonLabel(s), s.Get(0).Data)
+	if name, ok := s.Attr("name"); ok {
+		desc += fmt.Sprintf(" name=%s", name)
+	}
+	return desc + ")"
+}
+
+// normalizeSpace removes the spaces around the string, and replaces runs of
+// spaces inside it with a single space.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
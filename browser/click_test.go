@@ -0,0 +1,112 @@
+package browser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/dataxpe/surf/errors"
+	"github.com/headzoo/ut"
+)
+
+func TestClick(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlClick)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "<html><title>%s %s</title><body>%s</body></html>", r.Method, r.URL.Path, b)
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	click := func(f func() error) error {
+		ut.AssertNil(bow.Open(ts.URL))
+		return f()
+	}
+
+	ut.AssertNil(click(func() error { return bow.ClickLink(" Next\n page ") }))
+	ut.AssertEquals("GET /p/2", bow.Title())
+	ut.AssertNil(click(func() error { return bow.ClickLink("Logo") }))
+	ut.AssertEquals("GET /home", bow.Title())
+	ut.AssertNil(click(func() error { return bow.ClickLink("Region") }))
+	ut.AssertEquals("GET /area", bow.Title())
+	ut.AssertNil(click(func() error { return bow.ClickLinkMatch(regexp.MustCompile(`^Next`)) }))
+	ut.AssertEquals("GET /p/2", bow.Title())
+
+	err := click(func() error { return bow.ClickLink("Edit") })
+	ut.AssertTrue(errors.Is(err, errors.ErrAmbiguous))
+	var amb errors.Ambiguous
+	ut.AssertTrue(errors.As(err, &amb))
+	ut.AssertEquals(2, len(amb.Candidates()))
+	ut.AssertEquals("'Edit' ("+ts.URL+"/edit/1)", amb.Candidates()[0])
+	ut.AssertEquals("'Edit' ("+ts.URL+"/edit/2)", amb.Candidates()[1])
+
+	err = click(func() error { return bow.ClickLink("Missing") })
+	ut.AssertTrue(errors.Is(err, errors.ErrLinkNotFound))
+
+	ut.AssertNil(click(func() error { return bow.FollowRel("next") }))
+	ut.AssertEquals("GET /p/2", bow.Title())
+	ut.AssertNil(click(func() error { return bow.FollowRel("prev") }))
+	ut.AssertEquals("GET /p/0", bow.Title())
+
+	ut.AssertNil(click(func() error { return bow.ClickButton("Search") }))
+	ut.AssertEquals("POST /search", bow.Title())
+	ut.AssertEquals("q=go&go=1", bow.Find("body").Text())
+	ut.AssertNil(click(func() error { return bow.ClickButton("Go") }))
+	ut.AssertEquals("POST /search", bow.Title())
+	ut.AssertEquals("q=go&map.x=0&map.y=0", bow.Find("body").Text())
+	ut.AssertNil(click(func() error { return bow.ClickButton("Outside") }))
+	ut.AssertEquals("POST /search", bow.Title())
+	ut.AssertEquals("q=go&out=1", bow.Find("body").Text())
+
+	err = click(func() error { return bow.ClickButton("Save") })
+	ut.AssertTrue(errors.As(err, &amb))
+	ut.AssertEquals(2, len(amb.Candidates()))
+	ut.AssertEquals("'Save' (input name=save)", amb.Candidates()[0])
+	ut.AssertEquals("'Save' (button)", amb.Candidates()[1])
+	err = click(func() error { return bow.ClickButton("Off") })
+	ut.AssertTrue(errors.Is(err, errors.ErrInvalidFormValue))
+
+	ut.AssertNil(click(func() error { return bow.Click("area") }))
+	ut.AssertEquals("GET /area", bow.Title())
+	ut.AssertNil(click(func() error { return bow.Click("#settings button") }))
+	ut.AssertEquals("GET /settings", bow.Title())
+	err = click(func() error { return bow.Click("p") })
+	ut.AssertTrue(errors.Is(err, errors.ErrElementNotFound))
+}
+
+var htmlClick = `<!doctype html>
+<html>
+	<head>
+		<link rel="previous" href="/p/0">
+	</head>
+	<body>
+		<p>
+			<a href="/p/2" rel="next">Next
+				page</a>
+			<a href="/p/2">Next page</a>
+			<a href="/edit/1">Edit</a>
+			<a href="/edit/2">Edit</a>
+			<a href="/home"><img src="/logo.png" alt="Logo"></a>
+		</p>
+		<map name="m"><area href="/area" alt="Region"></map>
+		<form method="post" action="/search" id="search">
+			<input name="q" value="go">
+			<button name="go" value="1">Search</button>
+			<input type="image" name="map" alt="Go" src="/go.png">
+			<input type="submit" name="save" value="Save">
+			<input type="submit" name="off" value="Off" disabled>
+		</form>
+		<button form="search" name="out" value="1">Outside</button>
+		<form action="/settings" id="settings">
+			<button>Save</button>
+		</form>
+	</body>
+</html>
+`
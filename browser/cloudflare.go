@@ -0,0 +1,259 @@
+package browser
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Diggernaut/goquery"
+	"github.com/dataxpe/surf/errors"
+	"github.com/robertkrimen/otto"
+)
+
+// CloudflareSolver solves the JavaScript challenge of the Cloudflare
+// "I'm Under Attack" mode.
+//
+// Set the SURF_DEBUG_CF environment variable to print the challenge script
+// and the answer.
+type CloudflareSolver struct {
+	// Delay is the time waited before the answer is sent, which Cloudflare
+	// requires. Defaults to 4 seconds.
+	Delay time.Duration
+
+	// Sleep waits for the delay. Defaults to time.Sleep.
+	Sleep func(time.Duration)
+
+	// Host is the host name the answer is computed for. Defaults to the host
+	// of the challenge page.
+	Host string
+}
+
+// Detect returns true when the response is a Cloudflare challenge.
+func (s *CloudflareSolver) Detect(resp *http.Response) bool {
+	server := resp.Header.Get("Server")
+	return resp.StatusCode == 503 && (server == "cloudflare-nginx" || server == "cloudflare")
+}
+
+// Solve answers the challenge, and loads the page the challenge protects.
+func (s *CloudflareSolver) Solve(bow *Browser, resp *http.Response) error {
+	rurl := resp.Request.URL
+	if strings.Contains(rurl.String(), "chk_jschl") {
+		return errors.New("The answer to the challenge was refused.")
+	}
+
+	delay := s.Delay
+	if delay == 0 {
+		delay = 4 * time.Second
+	}
+	if s.Sleep != nil {
+		s.Sleep(delay)
+	} else {
+		time.Sleep(delay)
+	}
+
+	reader, err := decodeBody(resp)
+	if err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	dom, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	host := s.Host
+	if host == "" {
+		host = rurl.Host
+	}
+
+	js := dom.Find("script:contains(\"s,t,o,p,b,r,e,a,k,i,n,g\")").Text()
+	if js == "" {
+		js = dom.Find("script:contains(\"s,t,o,p, b,r,e,a,k,i,n,g\")").Text()
+		js = strings.Replace(js, "s,t,o,p, b,r,e,a,k,i,n,g", "s,t,o,p,b,r,e,a,k,i,n,g", -1)
+	}
+	if strings.Contains(js, "e = function(s)") {
+		return s.solvePost(bow, dom, js, host, rurl)
+	}
+	return s.solveGet(bow, dom, js, host, rurl)
+}
+
+// solvePost answers the challenge sent since December 2019, which is
+// answered with a POST request.
+func (s *CloudflareSolver) solvePost(bow *Browser, dom *goquery.Document, js, host string, rurl *url.URL) error {
+	cfDebug("js before", strings.Replace(js, ";", ";\n", -1))
+
+	htmlSrc, _ := dom.Html()
+	reKey := regexp.MustCompile("<div style=\"display:none;visibility:hidden;\" id=\".*?\">(.*?)<")
+	x := reKey.FindStringSubmatch(htmlSrc)
+	if len(x) == 0 {
+		return errors.New("The key of the challenge was not found.")
+	}
+	key := x[1]
+
+	re1 := regexp.MustCompile("setTimeout\\(function\\(\\){\\s+(var s,t,o,p,b,r,e,a,k,i,n,g,f.+?\\r?\\n[\\s\\S]+?a\\.value =.+?)\\r?\\n")
+	re2 := regexp.MustCompile("\\s{3,}[atf](?: = |\\.).+")
+	re31 := regexp.MustCompile("function\\(p\\){var p = eval\\(eval\\(e.*?; return \\+\\(p\\)}\\(\\)")
+	re32 := regexp.MustCompile("function\\(p\\){return eval\\(\\(.*?}")
+	re4 := regexp.MustCompile("\\s';\\s121'$")
+	re5 := regexp.MustCompile("a\\.value\\s*\\=")
+
+	jsm := re1.FindAllStringSubmatch(js, -1)
+	if len(jsm) < 1 {
+		return errors.New("The script of the challenge was not found.")
+	}
+	js = jsm[0][1]
+	js = strings.Replace(js, "s,t,o,p,b,r,e,a,k,i,n,g,f,", "s,t = \""+host+"\",o,p,b,r,e,a,k,i,n,g,f,", 1)
+	js = re2.ReplaceAllString(js, "")
+	js = re31.ReplaceAllString(js, key)
+	js = re32.ReplaceAllString(js, "t.charCodeAt")
+	js = re4.ReplaceAllString(js, "")
+	js = re5.ReplaceAllString(js, "return ")
+	js = strings.Replace(js, ";", ";\n", -1)
+	cfDebug("js", js)
+
+	data, err := otto.New().Eval("(function () {" + js + "})()")
+	if err != nil {
+		return errors.Wrap(err, "The script of the challenge failed.")
+	}
+	if _, err := data.ToInteger(); err != nil {
+		return errors.Wrap(err, "The answer to the challenge is not a number.")
+	}
+
+	action, _ := dom.Find("form[id=\"challenge-form\"]").Attr("action")
+	// Unescape HTML Entities Cloudflare introduced on the challenge request.
+	action = html.UnescapeString(action)
+	jschlVc, _ := dom.Find("input[name=\"jschl_vc\"]").Attr("value")
+	pass, _ := dom.Find("input[name=\"pass\"]").Attr("value")
+	r, _ := dom.Find("input[name=\"r\"]").Attr("value")
+
+	u := rurl.Scheme + "://" + rurl.Host + action
+	q := url.Values{}
+	q.Set("jschl_vc", jschlVc)
+	q.Add("pass", pass)
+	q.Add("r", r)
+	q.Add("jschl_answer", data.String())
+	cfDebug("query", q.Encode())
+
+	req, err := bow.buildRequest("POST", u, rurl, strings.NewReader(q.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	answerHeaders(bow, req, rurl)
+	if bow.profile == nil {
+		setDefaultHeader(req.Header, "Origin", rurl.Scheme+"://"+rurl.Host)
+		setDefaultHeader(req.Header, "Connection", "keep-alive")
+		setDefaultHeader(req.Header, "DNT", "1")
+	}
+
+	err = bow.httpRequest(req)
+	if err == nil && bow.StatusCode() == 403 {
+		if os.Getenv("SURF_DEBUG_CF") != "" || os.Getenv("SURF_DEBUG_HEADERS") != "" {
+			fmt.Fprintln(os.Stderr, "===== [DUMP 403 Response Header] =====\n", bow.ResponseHeaders())
+			fmt.Fprintln(os.Stderr, "===== [DUMP 403 Response Body] =====\n", bow.Body())
+		}
+		err = errors.New("The answer to the challenge was refused.")
+	}
+	return answerError(err, "POST", u)
+}
+
+// solveGet answers the challenge sent before December 2019, which is
+// answered with a GET request.
+func (s *CloudflareSolver) solveGet(bow *Browser, dom *goquery.Document, js, host string, rurl *url.URL) error {
+	re1 := regexp.MustCompile("setTimeout\\(function\\(\\){\\s+(var s,t,o,p,b,r,e,a,k,i,n,g,f.+?\\r?\\n[\\s\\S]+?a\\.value =.+?)\\r?\\n")
+	re2 := regexp.MustCompile("\\s{3,}[a-z](?: = |\\.).+")
+	re3 := regexp.MustCompile("[\\n\\\\']")
+	re4 := regexp.MustCompile(";\\s*\\d+\\s*$")
+	re5 := regexp.MustCompile("a\\.value\\s*\\=")
+
+	if jsRE := re1.FindAllStringSubmatch(js, -1); len(jsRE) > 0 {
+		js = jsRE[0][1]
+	}
+	js = strings.Replace(js, "s,t,o,p,b,r,e,a,k,i,n,g,f,", "s,t = \""+host+"\",o,p,b,r,e,a,k,i,n,g,f,", 1)
+	js = re2.ReplaceAllString(js, "")
+	js = re3.ReplaceAllString(js, "")
+	js = re4.ReplaceAllString(js, "")
+	js = re5.ReplaceAllString(js, "return ")
+	cfDebug("js (OLD VERSION)", js)
+
+	data, err := otto.New().Eval("(function () {" + js + "})()")
+	if err != nil {
+		return errors.Wrap(err, "The script of the challenge failed.")
+	}
+	if _, err := data.ToInteger(); err != nil {
+		return errors.Wrap(err, "The answer to the challenge is not a number.")
+	}
+
+	jschlVc, _ := dom.Find("input[name=\"jschl_vc\"]").Attr("value")
+	pass, _ := dom.Find("input[name=\"pass\"]").Attr("value")
+
+	ur, err := url.Parse(rurl.Scheme + "://" + rurl.Host + "/cdn-cgi/l/chk_jschl")
+	if err != nil {
+		return err
+	}
+	q := ur.Query()
+	q.Add("jschl_vc", jschlVc)
+	q.Add("pass", pass)
+	ur.RawQuery = q.Encode() + "&jschl_answer=" + data.String()
+	cfDebug("query", ur.RawQuery)
+
+	req, err := bow.buildRequest("GET", ur.String(), rurl, nil)
+	if err != nil {
+		return err
+	}
+	answerHeaders(bow, req, rurl)
+
+	err = bow.httpRequest(req)
+	return answerError(err, "GET", ur.String())
+}
+
+// answerHeaders sets the headers of the request sending the answer to the
+// challenge. The headers a browser profile sends are only set when the
+// browser has no profile. The headers of the browser are not changed.
+func answerHeaders(bow *Browser, req *http.Request, rurl *url.URL) {
+	setHeader(req.Header, "Referer", rurl.String())
+	if bow.profile != nil {
+		return
+	}
+	setDefaultHeader(req.Header, "Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8")
+	setDefaultHeader(req.Header, "Accept-Language", "en-US,en;q=0.9")
+	setDefaultHeader(req.Header, "Accept-Encoding", "gzip, deflate, br")
+	setDefaultHeader(req.Header, "upgrade-insecure-requests", "1")
+}
+
+// setDefaultHeader sets the header unless it is already set.
+func setDefaultHeader(h http.Header, name, value string) {
+	if !hasHeader(h, name) {
+		setHeader(h, name, value)
+	}
+}
+
+// answerError returns the error of the request sending the answer to the
+// challenge as a Challenge error, so the page loaded by the request is kept.
+func answerError(err error, method, u string) error {
+	if err == nil || errors.Is(err, errors.ErrChallenge) {
+		return err
+	}
+	cerr := errors.NewChallenge("The answer to the challenge failed.")
+	cerr.Cause = err
+	cerr.URL = u
+	cerr.Method = method
+	return cerr
+}
+
+// cfDebug prints a step of the challenge when the SURF_DEBUG_CF environment
+// variable is set.
+func cfDebug(title, text string) {
+	if os.Getenv("SURF_DEBUG_CF") != "" {
+		fmt.Fprintf(os.Stderr, "---------- %s -----------\n%s\n\n", title, text)
+	}
+}
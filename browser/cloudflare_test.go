@@ -111,6 +111,10 @@ func testCFrequest(t *testing.T, page string, method string, jschl_answer string
 	// Alright, now let's see if the browser does the same thing
 	bow := newDefaultTestBrowser()
 	bow.UseCookie(true)
+	bow.SetChallengeSolvers(&CloudflareSolver{
+		Host:  "torrentz2.eu",
+		Sleep: func(time.Duration) {},
+	})
 	if err := bow.Open(ts0.URL + "/feed?f=added%3A90d"); err != nil {
 		t.Errorf("Failed to open url: %s", ts0.URL)
 		return
@@ -119,6 +123,9 @@ func testCFrequest(t *testing.T, page string, method string, jschl_answer string
 	if bow.StatusCode() != 200 {
 		t.Fatalf("returned StatusCode is %d not 200",bow.StatusCode())
 	}
+	if h := bow.GetAllRequestHeaders(); h != "" {
+		t.Fatalf("the request headers of the browser were changed: %q", h)
+	}
 }
 
 
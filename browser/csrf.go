@@ -0,0 +1,245 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// CSRFSource is where the CSRF token of a site is found, and how it is sent
+// back. One of Meta, Input or Cookie is set.
+type CSRFSource struct {
+	// Meta is the name of the meta tag holding the token, e.g. "csrf-token".
+	Meta string
+
+	// Input is the name of the hidden input holding the token.
+	Input string
+
+	// Cookie is the name of the cookie holding the token.
+	Cookie string
+
+	// Header is the request header the token is sent in.
+	Header string
+
+	// Field is the form field the token is sent in by PostForm, along with
+	// the header.
+	Field string
+}
+
+// DefaultCSRFSources are the CSRF tokens of Rails, Laravel, Django, ASP.NET
+// and Angular sites.
+var DefaultCSRFSources = []CSRFSource{
+	{Meta: "csrf-token", Header: "X-CSRF-Token"},
+	{Input: "csrfmiddlewaretoken", Header: "X-CSRFToken", Field: "csrfmiddlewaretoken"},
+	{Input: "__RequestVerificationToken", Header: "RequestVerificationToken", Field: "__RequestVerificationToken"},
+	{Cookie: "XSRF-TOKEN", Header: "X-XSRF-TOKEN"},
+	{Cookie: "csrftoken", Header: "X-CSRFToken"},
+}
+
+// SetCSRF sends the CSRF tokens found in the given sources with the
+// requests made by PostForm, PostJSON and XHR to the hosts matching the
+// pattern, or stops sending them when sources is nil. The pattern is
+// matched the way SetCredentials matches it.
+//
+// Tokens in meta tags and hidden inputs are taken from the pages of the
+// hosts matching the pattern, and kept until a page has another token.
+// Tokens in cookies are taken from the cookie jar. Headers and fields which
+// are already set are not replaced.
+func (bow *Browser) SetCSRF(pattern string, sources []CSRFSource) {
+	if bow.csrf == nil {
+		bow.csrf = newCSRFStore()
+	}
+	bow.csrf.set(pattern, sources)
+}
+
+// csrfStore holds the CSRF sources of a browser, and the tokens found in
+// its pages.
+type csrfStore struct {
+	mu      sync.Mutex
+	sources map[string][]CSRFSource
+
+	// tokens are the tokens of each pattern, by the index of their source.
+	tokens map[string]map[int]string
+}
+
+// csrfToken is a token and the source it was found in.
+type csrfToken struct {
+	source CSRFSource
+	value  string
+}
+
+// newCSRFStore creates and returns a new *csrfStore type.
+func newCSRFStore() *csrfStore {
+	return &csrfStore{
+		sources: make(map[string][]CSRFSource),
+		tokens:  make(map[string]map[int]string),
+	}
+}
+
+// set sets the sources of the pattern.
+func (s *csrfStore) set(pattern string, sources []CSRFSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pattern = strings.ToLower(pattern)
+	if sources == nil {
+		delete(s.sources, pattern)
+	} else {
+		s.sources[pattern] = sources
+	}
+	delete(s.tokens, pattern)
+}
+
+// match returns the pattern matching the URL.
+func (s *csrfStore) match(u *url.URL) string {
+	patterns := make([]string, 0, len(s.sources))
+	for pattern := range s.sources {
+		patterns = append(patterns, pattern)
+	}
+	return matchHost(u, patterns)
+}
+
+// capture remembers the tokens in the meta tags and hidden inputs of the
+// current page.
+func (s *csrfStore) capture(bow *Browser) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pattern := s.match(bow.Url())
+	if pattern == "" {
+		return
+	}
+	for i, src := range s.sources[pattern] {
+		var token string
+		switch {
+		case src.Meta != "":
+			token = attrOr(bow.Find("meta[name='"+src.Meta+"']").First(), "content", "")
+		case src.Input != "":
+			token = attrOr(bow.Find("input[name='"+src.Input+"']").First(), "value", "")
+		}
+		if token == "" {
+			continue
+		}
+		if s.tokens[pattern] == nil {
+			s.tokens[pattern] = make(map[int]string)
+		}
+		s.tokens[pattern][i] = token
+	}
+}
+
+// find returns the tokens for a request to the URL.
+func (s *csrfStore) find(u *url.URL, cookies http.CookieJar) []csrfToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pattern := s.match(u)
+	if pattern == "" {
+		return nil
+	}
+	var tokens []csrfToken
+	for i, src := range s.sources[pattern] {
+		token := s.tokens[pattern][i]
+		if src.Cookie != "" && cookies != nil {
+			for _, c := range cookies.Cookies(u) {
+				if c.Name == src.Cookie {
+					// Scripts send the cookie the way decodeURIComponent
+					// decodes it.
+					token = c.Value
+					if v, err := url.PathUnescape(c.Value); err == nil {
+						token = v
+					}
+					break
+				}
+			}
+		}
+		if token != "" {
+			tokens = append(tokens, csrfToken{src, token})
+		}
+	}
+	return tokens
+}
+
+// redirectHeaders returns the CSRF headers to remove from a redirect of a
+// request to from, when to does not match the pattern of from.
+func (s *csrfStore) redirectHeaders(from, to *url.URL) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pattern := s.match(from)
+	if pattern == "" || s.match(to) == pattern {
+		return nil
+	}
+	var names []string
+	for _, src := range s.sources[pattern] {
+		if src.Header != "" {
+			names = append(names, src.Header)
+		}
+	}
+	return names
+}
+
+// captureCSRF remembers the CSRF tokens of the current page.
+func (bow *Browser) captureCSRF() {
+	if bow.csrf != nil && bow.state != nil && bow.state.Dom != nil {
+		bow.csrf.capture(bow)
+	}
+}
+
+// csrfTokens returns the CSRF tokens for a request to the URL.
+func (bow *Browser) csrfTokens(u *url.URL) []csrfToken {
+	if bow.csrf == nil {
+		return nil
+	}
+	var cookies http.CookieJar
+	if bow.client != nil {
+		cookies = bow.client.Jar
+	}
+	return bow.csrf.find(u, cookies)
+}
+
+// setCSRFHeaders sets the headers of the CSRF tokens for the request.
+func (bow *Browser) setCSRFHeaders(req *http.Request) {
+	for _, t := range bow.csrfTokens(req.URL) {
+		if t.source.Header != "" && !hasHeader(req.Header, t.source.Header) {
+			setHeader(req.Header, t.source.Header, t.value)
+		}
+	}
+}
+
+// stripCSRFHeaders removes the CSRF headers of the first request from a
+// redirect to a host which does not match its pattern.
+func (bow *Browser) stripCSRFHeaders(req *http.Request, first *url.URL) {
+	if bow.csrf == nil {
+		return
+	}
+	for _, name := range bow.csrf.redirectHeaders(first, req.URL) {
+		delHeader(req.Header, name)
+	}
+}
+
+// hasHeader returns true when the header is set, whatever the case of its
+// name.
+func hasHeader(h http.Header, name string) bool {
+	for key, values := range h {
+		if strings.EqualFold(key, name) && len(values) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// addCSRFFields returns a copy of the form data with the fields of the
+// CSRF tokens for a request to the URL.
+func (bow *Browser) addCSRFFields(u *url.URL, data url.Values) url.Values {
+	tokens := bow.csrfTokens(u)
+	if len(tokens) == 0 {
+		return data
+	}
+	values := make(url.Values, len(data)+len(tokens))
+	for k, v := range data {
+		values[k] = v
+	}
+	for _, t := range tokens {
+		if t.source.Field != "" && values.Get(t.source.Field) == "" {
+			values.Set(t.source.Field, t.value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,114 @@
+package browser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestCSRF(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: "x%3D1"})
+			fmt.Fprint(w, htmlCSRF)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, `<html><body><p id="rails">%s</p><p id="django">%s</p><p id="xsrf">%s</p><p id="xhr">%s</p><p id="type">%s</p><p id="body">%s</p></body></html>`,
+			r.Header.Get("X-CSRF-Token"), r.Header.Get("X-CSRFToken"), r.Header.Get("X-XSRF-TOKEN"),
+			r.Header.Get("X-Requested-With"), r.Header.Get("Content-Type"), b)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	bow := newDefaultTestBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertNil(bow.PostForm(ts.URL+"/echo", url.Values{"a": {"1"}}, bow.Url()))
+	ut.AssertEquals("", bow.Find("#rails").Text())
+	ut.AssertEquals("a=1", bow.Find("#body").Text())
+
+	bow.SetCSRF(u.Hostname(), DefaultCSRFSources)
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertNil(bow.PostForm(ts.URL+"/echo", url.Values{"a": {"1"}}, bow.Url()))
+	ut.AssertEquals("m1", bow.Find("#rails").Text())
+	ut.AssertEquals("d1", bow.Find("#django").Text())
+	ut.AssertEquals("x=1", bow.Find("#xsrf").Text())
+	ut.AssertEquals("", bow.Find("#xhr").Text())
+	ut.AssertEquals("a=1&csrfmiddlewaretoken=d1", bow.Find("#body").Text())
+
+	// The tokens are kept when the page has none.
+	ut.AssertNil(bow.PostJSON(ts.URL+"/echo", map[string]int{"a": 1}, bow.Url()))
+	ut.AssertEquals("m1", bow.Find("#rails").Text())
+	ut.AssertEquals("XMLHttpRequest", bow.Find("#xhr").Text())
+	ut.AssertEquals("application/json", bow.Find("#type").Text())
+	ut.AssertEquals(`{"a":1}`, bow.Find("#body").Text())
+
+	// Headers which are set are not replaced.
+	bow.AddRequestHeader("x-csrf-token", "mine")
+	ut.AssertNil(bow.XHR("get", ts.URL+"/echo", "", nil, bow.Url()))
+	ut.AssertEquals("mine", bow.Find("#rails").Text())
+	ut.AssertEquals("d1", bow.Find("#django").Text())
+	bow.DelRequestHeader("x-csrf-token")
+
+	// Tokens are not sent to other hosts.
+	bow.SetCSRF(u.Hostname(), nil)
+	bow.SetCSRF("example.com", DefaultCSRFSources)
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertNil(bow.PostJSON(ts.URL+"/echo", nil, bow.Url()))
+	ut.AssertEquals("", bow.Find("#rails").Text())
+	ut.AssertEquals("", bow.Find("#xsrf").Text())
+}
+
+func TestCSRFRedirect(t *testing.T) {
+	ut.Run(t)
+	var token string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-CSRF-Token")
+		fmt.Fprint(w, "<html><title>Other</title></html>")
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			fmt.Fprint(w, htmlCSRF)
+		case "/same":
+			http.Redirect(w, r, "/landing", http.StatusTemporaryRedirect)
+		case "/away":
+			http.Redirect(w, r, other.URL+"/landing", http.StatusTemporaryRedirect)
+		default:
+			fmt.Fprintf(w, "<html><title>%s</title></html>", r.Header.Get("X-CSRF-Token"))
+		}
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	bow := newDefaultTestBrowser()
+	bow.SetCSRF(u.Host, DefaultCSRFSources)
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertNil(bow.PostForm(ts.URL+"/same", url.Values{"a": {"1"}}, bow.Url()))
+	ut.AssertEquals("m1", bow.Title())
+
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertNil(bow.PostForm(ts.URL+"/away", url.Values{"a": {"1"}}, bow.Url()))
+	ut.AssertEquals("Other", bow.Title())
+	ut.AssertEquals("", token)
+}
+
+var htmlCSRF = `<!doctype html>
+<html>
+	<head>
+		<meta name="csrf-token" content="m1">
+	</head>
+	<body>
+		<form method="post">
+			<input type="hidden" name="csrfmiddlewaretoken" value="d1">
+		</form>
+	</body>
+</html>
+`
@@ -1,10 +1,12 @@
 package browser
 
 import (
-	"github.com/Diggernaut/goquery"
-	"github.com/Diggernaut/surf/errors"
+	"io"
 	"net/url"
 	"strings"
+
+	"github.com/Diggernaut/goquery"
+	"github.com/dataxpe/surf/errors"
 )
 
 // Submittable represents an element that may be submitted, such as a form.
@@ -12,6 +14,17 @@ type Submittable interface {
 	Method() string
 	Action() string
 	Input(name, value string) error
+	File(name, filename, contentType string, r io.Reader) error
+	Set(name, value string) error
+	Add(name, value string)
+	Check(name string, values ...string) error
+	Uncheck(name string, values ...string) error
+	Select(name string, values ...string) error
+	Radio(name, value string) error
+	Fields() []Field
+	Validate() error
+	Fill(v interface{}) (*FillReport, error)
+	Builder() *FormBuilder
 	Click(button string) error
 	Submit(noclick string) error
 	Dom() *goquery.Selection
@@ -19,42 +32,74 @@ type Submittable interface {
 
 // Form is the default form element.
 type Form struct {
-	bow         Browsable
-	selection   *goquery.Selection
-	method      string
-	action      string
-	fields      []*Field
-    checkboxes  []*Checkbox
-	buttons     []*Button
+	bow       Browsable
+	selection *goquery.Selection
+	method    string
+	action    string
+	enctype   string
+	controls  []*formControl
+
+	// charset is the character encoding of the submission set by a
+	// FormBuilder, which takes precedence over the accept-charset attribute.
+	charset string
 }
-type Checkbox struct {
-	name        string
-	value       string
-    checked     bool
+
+// formControl is an input, button, select or textarea element owned by a
+// form.
+type formControl struct {
+	node *goquery.Selection
+
+	// typ is the type of the control the way the DOM reports it, e.g.
+	// "text", "checkbox", "submit", "select-one", "select-multiple" or
+	// "textarea".
+	typ      string
+	name     string
+	value    string
+	checked  bool
+	disabled bool
+	required bool
+	options  []*formOption
+	files    []*formFile
 }
-type Field struct {
-	name        string
-	value       string
+
+// formOption is an option of a select control.
+type formOption struct {
+	value    string
+	label    string
+	selected bool
+	disabled bool
+
+	// added is true when the option is not in the page, and was added by
+	// Input.
+	added bool
 }
-type Button struct {
-	name        string
-	value       string
+
+// formFile is a file chosen for a file input.
+type formFile struct {
+	filename    string
+	contentType string
+	reader      io.Reader
 }
 
+// formEntry is a name and value pair of the data sent by a form. The value
+// of a file entry is the file name, and typ is the type of the control.
+type formEntry struct {
+	name  string
+	value string
+	file  *formFile
+	typ   string
+}
 
 // NewForm creates and returns a *Form type.
 func NewForm(bow Browsable, s *goquery.Selection) *Form {
-	fields, checkboxes, buttons := serializeForm(s)
 	method, action := formAttributes(bow, s)
-
 	return &Form{
-		bow:        bow,
-		selection:  s,
-		method:     method,
-        action:     action,
-		fields:     fields,
-		checkboxes: checkboxes,
-		buttons:    buttons,
+		bow:       bow,
+		selection: s,
+		method:    method,
+		action:    action,
+		enctype:   formEnctype(attrOr(s, "enctype", "")),
+		controls:  serializeForm(s),
 	}
 }
 
@@ -70,24 +115,36 @@ func (f *Form) Action() string {
 }
 
 // Input sets the value of a form field.
+//
+// Radio buttons, checkboxes and select options with the given value are
+// selected. A value which is not among the options of a select is added to
+// its options. Use Set to only accept the values of the options.
 func (f *Form) Input(name, value string) error {
-    found := false
-    for _, f := range f.fields {
-        if f.name == name {
-            f.value = value
-            found = true
-        }
-    }
-    for _, c := range f.checkboxes {
-        if c.name == name && c.value == value {
-            if c.checked {
-                c.checked = false
-            } else {
-                c.checked = true
-            }
-            found = true
-        }
-    }
+	found := false
+	for _, c := range f.controls {
+		if c.name != name {
+			continue
+		}
+		switch c.typ {
+		case "submit", "image", "reset", "button", "file":
+		case "checkbox":
+			if c.value == value {
+				c.checked = true
+				found = true
+			}
+		case "radio":
+			if c.value == value {
+				f.checkRadio(c)
+				found = true
+			}
+		case "select-one", "select-multiple":
+			c.selectOption(value)
+			found = true
+		default:
+			c.value = value
+			found = true
+		}
+	}
 	if found {
 		return nil
 	}
@@ -95,36 +152,63 @@ func (f *Form) Input(name, value string) error {
 		"No input found with name '%s'.", name)
 }
 
+// File chooses the file sent by the file input with the given name. The
+// file is added to the files already chosen when the input accepts multiple
+// files, and replaces them otherwise. Its contents are read from r when the
+// form is submitted. File fields added with a FormBuilder accept multiple
+// files.
+func (f *Form) File(name, filename, contentType string, r io.Reader) error {
+	for _, c := range f.controls {
+		if c.name != name || c.typ != "file" {
+			continue
+		}
+		file := &formFile{filename: filename, contentType: contentType, reader: r}
+		multiple := c.node == nil
+		if !multiple {
+			_, multiple = c.node.Attr("multiple")
+		}
+		if multiple {
+			c.files = append(c.files, file)
+		} else {
+			c.files = []*formFile{file}
+		}
+		return nil
+	}
+	return errors.NewElementNotFound(
+		"No file input found with name '%s'.", name)
+}
+
 // Submit submits the form.
-// Clicks the first button in the form, or submits the form without using
-// any button when the form does not contain any buttons.
+// Clicks the first submit button in the form, or submits the form without
+// using any button when the form does not contain any submit buttons, or
+// when noclick is "noclick".
 func (f *Form) Submit(noclick string) error {
-	if noclick != "noclick" && len(f.buttons) > 0 {
-		for _, b := range f.buttons {
-			return f.Click(b.name)
+	if noclick != "noclick" {
+		for _, c := range f.controls {
+			if c.isSubmitter() {
+				if c.disabled {
+					break
+				}
+				return f.send(c)
+			}
 		}
 	}
-	return f.send("", "")
+	return f.send(nil)
 }
 
 // Click submits the form by clicking the button with the given name.
 func (f *Form) Click(button string) error {
-    found := false
-    button_name := ""
-    button_value := ""
-    for _, b := range f.buttons {
-        if b.name == button {
-            found = true
-            button_name = b.name
-            button_value = b.value
-            break
-        }
-    }
-	if !found {
-		return errors.NewInvalidFormValue(
-			"Form does not contain a button with the name '%s'.", button)
-	}
-	return f.send(button_name, button_value)
+	for _, c := range f.controls {
+		if c.name == button && c.isSubmitter() {
+			if c.disabled {
+				return errors.NewInvalidFormValue(
+					"The button with the name '%s' is disabled.", button)
+			}
+			return f.send(c)
+		}
+	}
+	return errors.NewInvalidFormValue(
+		"Form does not contain a button with the name '%s'.", button)
 }
 
 // Dom returns the inner *goquery.Selection.
@@ -132,149 +216,350 @@ func (f *Form) Dom() *goquery.Selection {
 	return f.selection
 }
 
-// send submits the form.
-func (f *Form) send(buttonName, buttonValue string) error {
-	method, ok := f.selection.Attr("method")
-	if !ok {
-		method = "GET"
+// checkRadio checks the radio button, and unchecks the other radio buttons
+// of its group.
+func (f *Form) checkRadio(radio *formControl) {
+	for _, c := range f.controls {
+		if c.typ == "radio" && c.name == radio.name {
+			c.checked = c == radio
+		}
 	}
-	action, ok := f.selection.Attr("action")
-	if !ok {
-		action = f.bow.Url().String()
+}
+
+// send submits the form with the given submit button, which may be nil.
+func (f *Form) send(submitter *formControl) error {
+	method, action, enctype := f.method, f.action, f.enctype
+	if submitter != nil {
+		if m, ok := submitter.node.Attr("formmethod"); ok {
+			method = formMethod(m)
+		}
+		if a, ok := submitter.node.Attr("formaction"); ok {
+			action = resolveFormAction(f.bow, a)
+		}
+		if e, ok := submitter.node.Attr("formenctype"); ok {
+			enctype = formEnctype(e)
+		}
 	}
-	aurl, err := url.Parse(action)
-	if err != nil {
-		return err
+	if action == "" {
+		return errors.NewLocation("Form action is not a valid URL.")
+	}
+	if f.validates(submitter) {
+		if err := f.Validate(); err != nil {
+			return err
+		}
 	}
-	aurl = f.bow.ResolveUrl(aurl)
 
-	values := make(url.Values)
-	for _, field := range f.fields {
-		values.Add(field.name, field.value)
+	entries := encodeEntries(f.dataSet(submitter), f.formCharset())
+	if method == "POST" && enctype == "text/plain" {
+		return f.bow.Post(action, "text/plain", strings.NewReader(encodeTextPlain(entries)), f.bow.Url())
 	}
-	for _, field := range f.checkboxes {
-        if field.checked {
-		    values.Add(field.name, field.value)
-        }
+	if method == "POST" && enctype == "multipart/form-data" {
+		m := NewMultipart()
+		for _, e := range entries {
+			if e.file != nil {
+				m.File(e.name, e.file.filename, e.file.contentType, e.file.reader)
+			} else {
+				m.Field(e.name, e.value)
+			}
+		}
+		return f.bow.PostMultipartParts(action, m, f.bow.Url())
 	}
-	if buttonName != "" {
-		values.Add(buttonName, buttonValue)
+
+	query := encodeURLEntries(entries)
+	if method == "GET" {
+		aurl, err := url.Parse(action)
+		if err != nil {
+			return err
+		}
+		aurl.RawQuery = query
+		return f.bow.Get(aurl.String(), f.bow.Url())
 	}
+	return f.bow.Post(action, "application/x-www-form-urlencoded", strings.NewReader(query), f.bow.Url())
+}
 
-	if strings.ToUpper(method) == "GET" {
-		return f.bow.OpenForm(aurl.String(), values)
-	} else {
-		enctype, _ := f.selection.Attr("enctype")
-		if enctype == "multipart/form-data" {
-			return f.bow.PostMultipart(aurl.String(), values, nil)
+// dataSet returns the name and value pairs sent when the form is submitted
+// with the given submit button, in document order, as described in the
+// "constructing the entry list" algorithm of the HTML standard.
+func (f *Form) dataSet(submitter *formControl) []formEntry {
+	var entries []formEntry
+	for _, c := range f.controls {
+		if c.disabled {
+			continue
+		}
+		switch c.typ {
+		case "submit", "image":
+			if c != submitter {
+				continue
+			}
+			if c.typ == "image" {
+				prefix := ""
+				if c.name != "" {
+					prefix = c.name + "."
+				}
+				entries = append(entries,
+					formEntry{name: prefix + "x", value: "0"},
+					formEntry{name: prefix + "y", value: "0"})
+				continue
+			}
+		case "reset", "button":
+			continue
+		case "file":
+			if c.name == "" {
+				continue
+			}
+			if len(c.files) == 0 {
+				entries = append(entries, formEntry{name: c.name, file: &formFile{}, typ: c.typ})
+			}
+			for _, file := range c.files {
+				entries = append(entries, formEntry{name: c.name, value: file.filename, file: file, typ: c.typ})
+			}
+			continue
+		case "checkbox", "radio":
+			if !c.checked {
+				continue
+			}
+		case "select-one", "select-multiple":
+			if c.name == "" {
+				continue
+			}
+			for _, o := range c.options {
+				if o.selected && !o.disabled {
+					entries = append(entries, formEntry{name: c.name, value: o.value})
+				}
+			}
+			continue
+		}
+		if c.name != "" {
+			entries = append(entries, formEntry{name: c.name, value: c.value, typ: c.typ})
 		}
-		return f.bow.PostForm(aurl.String(), values, nil)
 	}
+	return entries
+}
 
-	return nil
+// isSubmitter returns true when the control is a submit button.
+func (c *formControl) isSubmitter() bool {
+	return c.typ == "submit" || c.typ == "image"
 }
 
-// Serialize converts the form fields into a url.Values type.
-// Returns two url.Value types. The first is the form field values, and the
-// second is the form button values.
-func serializeForm(sel *goquery.Selection) ([]*Field, []*Checkbox, []*Button) {
-	var fields []*Field
-	var checkboxes []*Checkbox
-	var buttons []*Button
-
-	input := sel.Find("input,button,textarea,select")
-	if input.Length() > 0 {
-        input.Each(func(_ int, s *goquery.Selection) {
-            name, ok := s.Attr("name")
-            if ok {
-                typ, ok := s.Attr("type")
-                if s.Is("input") && ok || s.Is("textarea") {
-                    if typ == "submit" {
-                        val, ok := s.Attr("value")
-                        if !ok {
-                            val = ""
-                        }
-                        buttons = append(buttons, &Button{
-                            name: name,
-                            value: val,
-                        })
-                    } else if typ == "radio" {
-                        val, ok := s.Attr("value")
-                        if !ok {
-                            val = ""
-                        }
-                        _, ok = s.Attr("checked")
-                        if !ok {
-                            val = ""
-                        }
-                        if val != "" {
-                            fields = append(fields, &Field{
-                                name: name,
-                                value: val,
-                            })
-                        }
-                    } else if typ == "checkbox" { 
-                        val, ok := s.Attr("value")
-                        if !ok {
-                            val = ""
-                        }
-                        checked := true
-                        _, ok = s.Attr("checked")
-                        if !ok {
-                            checked = false
-                        }
-                        checkboxes = append(checkboxes, &Checkbox{
-                            name: name,
-                            value: val,
-                            checked: checked,
-                        })
-                    } else {
-                        val, ok := s.Attr("value")
-                        if !ok {
-                            val = ""
-                        }
-                        fields = append(fields, &Field{
-                            name: name,
-                            value: val,
-                        })
-                    }
-                } else if s.Is("select") {
-                    options := s.Find("option")
-                    val := ""
-                    options.Each(func(idx int, option *goquery.Selection) {
-                        _, ok := option.Attr("selected")
-                        if idx == 0 || ok {
-                            value, ok := option.Attr("value")
-                            if ok {
-                                val = value
-                            }
-                        }
-                    })
-                    fields = append(fields, &Field{
-                        name: name,
-                        value: val,
-                    })
-                }
-            }
-        })
-	}
-	return fields, checkboxes, buttons
+// selectOption selects the option with the given value. The other options
+// are deselected unless the select allows multiple values.
+func (c *formControl) selectOption(value string) {
+	var found *formOption
+	for _, o := range c.options {
+		if o.value == value && found == nil {
+			found = o
+		}
+	}
+	if found == nil {
+		found = &formOption{value: value, label: value, added: true}
+		c.options = append(c.options, found)
+	}
+	if c.typ == "select-one" {
+		for _, o := range c.options {
+			o.selected = false
+		}
+	}
+	found.selected = true
+}
+
+// serializeForm returns the controls owned by the form in document order.
+// These are the controls inside the form, and the controls elsewhere in the
+// document whose form attribute is the id of the form.
+func serializeForm(sel *goquery.Selection) []*formControl {
+	var controls []*formControl
+	scope := sel
+	id := attrOr(sel, "id", "")
+	if id != "" {
+		if root := sel.Parents().Last(); root.Length() > 0 {
+			scope = root
+		}
+	}
+	form := sel.Get(0)
+
+	scope.Find("input,button,textarea,select").Each(func(_ int, s *goquery.Selection) {
+		if owner, ok := s.Attr("form"); ok {
+			if id == "" || owner != id {
+				return
+			}
+		} else if s.Closest("form").Get(0) != form {
+			return
+		}
+		c := newFormControl(s)
+		if c == nil {
+			return
+		}
+		// Only the last checked radio button of a group stays checked.
+		if c.typ == "radio" && c.checked {
+			for _, other := range controls {
+				if other.typ == "radio" && other.name == c.name {
+					other.checked = false
+				}
+			}
+		}
+		controls = append(controls, c)
+	})
+	return controls
+}
+
+// newFormControl returns the control for the element, or nil when the
+// element is never submitted.
+func newFormControl(s *goquery.Selection) *formControl {
+	c := &formControl{
+		node:     s,
+		name:     attrOr(s, "name", ""),
+		disabled: isDisabled(s),
+	}
+	_, c.required = s.Attr("required")
+	switch {
+	case s.Is("textarea"):
+		c.typ = "textarea"
+		c.value = s.Text()
+	case s.Is("select"):
+		c.typ = "select-one"
+		if _, ok := s.Attr("multiple"); ok {
+			c.typ = "select-multiple"
+		}
+		c.options = selectOptions(s, c.typ == "select-one")
+	case s.Is("button"):
+		c.typ = strings.ToLower(attrOr(s, "type", "submit"))
+		if c.typ != "reset" && c.typ != "button" {
+			c.typ = "submit"
+		}
+		c.value = attrOr(s, "value", "")
+	default:
+		c.typ = strings.ToLower(attrOr(s, "type", "text"))
+		c.value = attrOr(s, "value", "")
+		switch c.typ {
+		case "checkbox", "radio":
+			_, c.checked = s.Attr("checked")
+			if _, ok := s.Attr("value"); !ok {
+				c.value = "on"
+			}
+		case "file":
+			c.value = ""
+		case "":
+			c.typ = "text"
+		}
+	}
+	if c.name == "" && c.typ != "submit" && c.typ != "image" {
+		return nil
+	}
+	return c
+}
+
+// selectOptions returns the options of the select element. When no option
+// of a single value select is selected, the first enabled option is.
+func selectOptions(s *goquery.Selection, single bool) []*formOption {
+	var options []*formOption
+	var last *formOption
+	s.Find("option").Each(func(_ int, o *goquery.Selection) {
+		label := strings.Join(strings.Fields(o.Text()), " ")
+		opt := &formOption{
+			value: attrOr(o, "value", label),
+			label: label,
+		}
+		_, opt.selected = o.Attr("selected")
+		_, opt.disabled = o.Attr("disabled")
+		if _, ok := o.ParentsFiltered("optgroup").Attr("disabled"); ok {
+			opt.disabled = true
+		}
+		if opt.selected {
+			last = opt
+		}
+		options = append(options, opt)
+	})
+	if !single {
+		return options
+	}
+	for _, o := range options {
+		o.selected = false
+	}
+	if last == nil {
+		for _, o := range options {
+			if !o.disabled {
+				last = o
+				break
+			}
+		}
+	}
+	if last != nil {
+		last.selected = true
+	}
+	return options
 }
 
+// isDisabled returns true when the element is disabled, or is inside a
+// disabled fieldset and not inside the first legend of that fieldset.
+func isDisabled(s *goquery.Selection) bool {
+	if _, ok := s.Attr("disabled"); ok {
+		return true
+	}
+	disabled := false
+	s.ParentsFiltered("fieldset[disabled]").EachWithBreak(func(_ int, fs *goquery.Selection) bool {
+		legend := fs.ChildrenFiltered("legend").First()
+		if legend.Length() == 0 || !legend.Contains(s.Get(0)) {
+			disabled = true
+		}
+		return !disabled
+	})
+	return disabled
+}
+
+// formAttributes returns the method and the absolute action URL of the form.
 func formAttributes(bow Browsable, s *goquery.Selection) (string, string) {
-	method, ok := s.Attr("method")
-	if !ok {
-		method = "GET"
+	return formMethod(attrOr(s, "method", "")), resolveFormAction(bow, attrOr(s, "action", ""))
+}
+
+// formMethod returns the form method for the method attribute. Methods other
+// than GET and POST are submitted as GET.
+func formMethod(method string) string {
+	if strings.EqualFold(method, "post") {
+		return "POST"
 	}
-	action, ok := s.Attr("action")
-	if !ok {
-		action = bow.Url().String()
+	return "GET"
+}
+
+// formEnctype returns the form encoding type for the enctype attribute.
+func formEnctype(enctype string) string {
+	switch strings.ToLower(enctype) {
+	case "multipart/form-data":
+		return "multipart/form-data"
+	case "text/plain":
+		return "text/plain"
+	}
+	return "application/x-www-form-urlencoded"
+}
+
+// resolveFormAction returns the absolute action URL, which is the URL of the
+// page when the action is empty. Returns an empty string when the action is
+// not a valid URL, or is relative and no page was loaded.
+func resolveFormAction(bow Browsable, action string) string {
+	action = strings.TrimSpace(action)
+	page := bow.Url()
+	if action == "" {
+		if page == nil {
+			return ""
+		}
+		return page.String()
 	}
 	aurl, err := url.Parse(action)
 	if err != nil {
-		return "", ""
+		return ""
+	}
+	if page == nil {
+		if !aurl.IsAbs() {
+			return ""
+		}
+		return aurl.String()
 	}
-	aurl = bow.ResolveUrl(aurl)
+	return bow.ResolveUrl(aurl).String()
+}
 
-	return strings.ToUpper(method), aurl.String()
+// attrOr returns the value of the attribute, or the default value when the
+// element does not have the attribute.
+func attrOr(s *goquery.Selection, name, def string) string {
+	if val, ok := s.Attr(name); ok {
+		return val
+	}
+	return def
 }
@@ -0,0 +1,155 @@
+package browser
+
+import (
+	"io"
+	"strings"
+
+	"github.com/Diggernaut/goquery"
+)
+
+// FormBuilder builds a form which is not in the page, such as a form the
+// scripts of a page create, or a copy of a page form with other fields.
+//
+//	fm := browser.NewFormBuilder(bow).
+//		Method("POST").
+//		Action("/session").
+//		Field("user", "joe").
+//		Field("password", "secret").
+//		Form()
+//	err := fm.Submit("")
+type FormBuilder struct {
+	bow       Browsable
+	selection *goquery.Selection
+	method    string
+	action    string
+	enctype   string
+	charset   string
+	controls  []*formControl
+}
+
+// NewFormBuilder returns a builder of an empty form, which is submitted
+// with the GET method to the current page unless a method and action are
+// set.
+func NewFormBuilder(bow Browsable) *FormBuilder {
+	return &FormBuilder{
+		bow:     bow,
+		method:  "GET",
+		enctype: "application/x-www-form-urlencoded",
+	}
+}
+
+// Builder returns a builder of a copy of the form, with the method, action,
+// encoding type and fields of the form. Changes made to the copy do not
+// change the form.
+func (f *Form) Builder() *FormBuilder {
+	b := &FormBuilder{
+		bow:       f.bow,
+		selection: f.selection,
+		method:    f.method,
+		action:    f.action,
+		enctype:   f.enctype,
+		charset:   f.charset,
+	}
+	for _, c := range f.controls {
+		b.controls = append(b.controls, c.clone())
+	}
+	return b
+}
+
+// Method sets the form method, e.g. "GET" or "POST".
+func (b *FormBuilder) Method(method string) *FormBuilder {
+	b.method = formMethod(method)
+	return b
+}
+
+// Action sets the form action URL. A relative URL is resolved against the
+// URL of the current page when the form is built.
+func (b *FormBuilder) Action(action string) *FormBuilder {
+	b.action = action
+	return b
+}
+
+// Enctype sets the form encoding type, e.g. "multipart/form-data".
+func (b *FormBuilder) Enctype(enctype string) *FormBuilder {
+	b.enctype = formEnctype(enctype)
+	return b
+}
+
+// Charset sets the character encoding of the form submission, the way the
+// accept-charset attribute of a form does.
+func (b *FormBuilder) Charset(charset string) *FormBuilder {
+	b.charset = charset
+	return b
+}
+
+// Field adds a text field, which is sent after the fields already added.
+// Fields may share a name.
+func (b *FormBuilder) Field(name, value string) *FormBuilder {
+	b.controls = append(b.controls, &formControl{
+		typ:   "text",
+		name:  name,
+		value: value,
+	})
+	return b
+}
+
+// File adds a file field. The contents of the file are read from r when the
+// form is submitted.
+func (b *FormBuilder) File(name, filename, contentType string, r io.Reader) *FormBuilder {
+	b.controls = append(b.controls, &formControl{
+		typ:   "file",
+		name:  name,
+		files: []*formFile{{filename: filename, contentType: contentType, reader: r}},
+	})
+	return b
+}
+
+// Remove removes every field with the given name.
+func (b *FormBuilder) Remove(name string) *FormBuilder {
+	controls := b.controls[:0]
+	for _, c := range b.controls {
+		if c.name != name {
+			controls = append(controls, c)
+		}
+	}
+	b.controls = controls
+	return b
+}
+
+// Form returns the form. It is submitted the same way as a form of the
+// page.
+func (b *FormBuilder) Form() *Form {
+	sel := b.selection
+	if sel == nil {
+		dom, _ := goquery.NewDocumentFromReader(strings.NewReader("<form></form>"))
+		sel = dom.Find("form")
+	}
+	action := b.action
+	if b.selection == nil || action != "" {
+		action = resolveFormAction(b.bow, action)
+	}
+	f := &Form{
+		bow:       b.bow,
+		selection: sel,
+		method:    b.method,
+		action:    action,
+		enctype:   b.enctype,
+		charset:   b.charset,
+	}
+	for _, c := range b.controls {
+		f.controls = append(f.controls, c.clone())
+	}
+	return f
+}
+
+// clone returns a copy of the control.
+func (c *formControl) clone() *formControl {
+	cp := *c
+	cp.options = make([]*formOption, len(c.options))
+	for i, o := range c.options {
+		option := *o
+		cp.options[i] = &option
+	}
+	cp.files = append([]*formFile(nil), c.files...)
+	return &cp
+}
@@ -0,0 +1,243 @@
+package browser
+
+import (
+	"github.com/dataxpe/surf/errors"
+)
+
+// Field describes a control of a form.
+type Field struct {
+	// Name is the name of the control.
+	Name string
+
+	// Type is the type of the control, e.g. "text", "checkbox", "submit",
+	// "select-one", "select-multiple" or "textarea".
+	Type string
+
+	// Value is the value of the control. It is the first selected option of
+	// a select, and the value sent when a checkbox or radio button is checked.
+	Value string
+
+	// Checked is true when a checkbox or radio button is checked.
+	Checked bool
+
+	// Options are the options of a select.
+	Options []Option
+
+	// Disabled is true when the control is disabled, and is not sent.
+	Disabled bool
+
+	// Required is true when the control has the required attribute.
+	Required bool
+}
+
+// Option describes an option of a select.
+type Option struct {
+	Value    string
+	Label    string
+	Selected bool
+	Disabled bool
+}
+
+// Fields returns the controls of the form in document order, with their
+// current values.
+func (f *Form) Fields() []Field {
+	fields := make([]Field, 0, len(f.controls))
+	for _, c := range f.controls {
+		field := Field{
+			Name:     c.name,
+			Type:     c.typ,
+			Value:    c.value,
+			Checked:  c.checked,
+			Disabled: c.disabled,
+			Required: c.required,
+		}
+		if c.typ == "file" && len(c.files) > 0 {
+			field.Value = c.files[0].filename
+		}
+		for _, o := range c.options {
+			field.Options = append(field.Options, Option{
+				Value:    o.value,
+				Label:    o.label,
+				Selected: o.selected,
+				Disabled: o.disabled,
+			})
+			if o.selected && field.Value == "" {
+				field.Value = o.value
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// Set sets the value of the form field with the given name. Checkboxes,
+// radio buttons and select options must have the given value.
+func (f *Form) Set(name, value string) error {
+	controls := f.named(name)
+	if len(controls) == 0 {
+		return errors.NewElementNotFound(
+			"No input found with name '%s'.", name)
+	}
+	switch controls[0].typ {
+	case "checkbox":
+		return f.Check(name, value)
+	case "radio":
+		return f.Radio(name, value)
+	case "select-one", "select-multiple":
+		return f.Select(name, value)
+	case "submit", "image", "reset", "button", "file":
+		return errors.NewInvalidFormValue(
+			"The value of the %s input '%s' cannot be set.", controls[0].typ, name)
+	}
+	for _, c := range controls {
+		c.value = value
+	}
+	return nil
+}
+
+// Add adds a hidden field to the form, which is sent after the fields of the
+// page. Use it to send fields that are added by the scripts of a page.
+func (f *Form) Add(name, value string) {
+	f.controls = append(f.controls, &formControl{
+		typ:   "hidden",
+		name:  name,
+		value: value,
+	})
+}
+
+// Check checks the checkboxes with the given name and values, or every
+// checkbox with the given name when no values are given.
+func (f *Form) Check(name string, values ...string) error {
+	return f.setChecked(name, values, true)
+}
+
+// Uncheck unchecks the checkboxes with the given name and values, or every
+// checkbox with the given name when no values are given.
+func (f *Form) Uncheck(name string, values ...string) error {
+	return f.setChecked(name, values, false)
+}
+
+// setChecked checks or unchecks checkboxes. Every value is looked up before
+// a checkbox is changed, so the form is left alone on error.
+func (f *Form) setChecked(name string, values []string, checked bool) error {
+	boxes, matched, err := f.checkboxes(name, values)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		matched = boxes
+	}
+	for _, c := range matched {
+		c.checked = checked
+	}
+	return nil
+}
+
+// checkboxes returns the checkboxes with the given name, and those of them
+// having one of the given values. An error is returned when there is no
+// checkbox with the name, or a value has no checkbox.
+func (f *Form) checkboxes(name string, values []string) ([]*formControl, []*formControl, error) {
+	var boxes []*formControl
+	for _, c := range f.named(name) {
+		if c.typ == "checkbox" {
+			boxes = append(boxes, c)
+		}
+	}
+	if len(boxes) == 0 {
+		return nil, nil, errors.NewElementNotFound(
+			"No checkbox found with name '%s'.", name)
+	}
+	var matched []*formControl
+	for _, v := range values {
+		found := false
+		for _, c := range boxes {
+			if c.value == v {
+				matched = append(matched, c)
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil, errors.NewInvalidFormValue(
+				"No checkbox '%s' has the value '%s'.", name, v)
+		}
+	}
+	return boxes, matched, nil
+}
+
+// Select selects the options of the select with the given name which have
+// the given values, and deselects the other options. A select which does not
+// accept multiple values must be given one value.
+func (f *Form) Select(name string, values ...string) error {
+	var sel *formControl
+	for _, c := range f.named(name) {
+		if c.typ == "select-one" || c.typ == "select-multiple" {
+			sel = c
+			break
+		}
+	}
+	if sel == nil {
+		return errors.NewElementNotFound(
+			"No select found with name '%s'.", name)
+	}
+	if sel.typ == "select-one" && len(values) != 1 {
+		return errors.NewInvalidFormValue(
+			"The select '%s' accepts one value, got %d.", name, len(values))
+	}
+	for _, v := range values {
+		if !sel.hasOption(v) {
+			return errors.NewInvalidFormValue(
+				"The select '%s' has no option with the value '%s'.", name, v)
+		}
+	}
+	for _, o := range sel.options {
+		o.selected = false
+	}
+	for _, v := range values {
+		sel.selectOption(v)
+	}
+	return nil
+}
+
+// Radio checks the radio button with the given name and value, and unchecks
+// the other radio buttons of its group.
+func (f *Form) Radio(name, value string) error {
+	found := false
+	for _, c := range f.named(name) {
+		if c.typ != "radio" {
+			continue
+		}
+		found = true
+		if c.value == value {
+			f.checkRadio(c)
+			return nil
+		}
+	}
+	if !found {
+		return errors.NewElementNotFound(
+			"No radio button found with name '%s'.", name)
+	}
+	return errors.NewInvalidFormValue(
+		"No radio button '%s' has the value '%s'.", name, value)
+}
+
+// named returns the controls with the given name.
+func (f *Form) named(name string) []*formControl {
+	var controls []*formControl
+	for _, c := range f.controls {
+		if c.name == name {
+			controls = append(controls, c)
+		}
+	}
+	return controls
+}
+
+// hasOption returns true when the select has an enabled option with the
+// given value.
+func (c *formControl) hasOption(value string) bool {
+	for _, o := range c.options {
+		if o.value == value && !o.disabled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package browser
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/Diggernaut/goquery"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// formCharset returns the name of the character encoding of the form
+// submission. It is the first encoding of the accept-charset attribute
+// which is supported, or the encoding of the page. The encoding set by a
+// FormBuilder comes first.
+func (f *Form) formCharset() string {
+	if f.charset != "" {
+		if name := lookupCharset(f.charset); name != "" {
+			return name
+		}
+	}
+	if accept, ok := f.selection.Attr("accept-charset"); ok {
+		for _, label := range strings.Fields(strings.Replace(accept, ",", " ", -1)) {
+			if name := lookupCharset(label); name != "" {
+				return name
+			}
+		}
+	}
+	return documentCharset(f.bow)
+}
+
+// lookupCharset returns the name of the encoding with the given label, or an
+// empty string when it is not supported. Forms are never sent in UTF-16, which
+// is replaced by UTF-8 as the HTML standard requires.
+func lookupCharset(label string) string {
+	_, name := charset.Lookup(label)
+	if strings.HasPrefix(name, "utf-16") {
+		return "utf-8"
+	}
+	return name
+}
+
+// documentCharset returns the name of the character encoding of the current
+// page, from the Content-Type header or the meta tags of the page. Returns
+// "utf-8" when the page does not give an encoding, or no page was loaded.
+func documentCharset(bow Browsable) string {
+	if bow.Url() == nil {
+		return "utf-8"
+	}
+	if _, params, err := mime.ParseMediaType(bow.ResponseHeaders().Get("Content-Type")); err == nil {
+		if name := lookupCharset(params["charset"]); name != "" {
+			return name
+		}
+	}
+	if label, ok := bow.Find("meta[charset]").First().Attr("charset"); ok {
+		if name := lookupCharset(label); name != "" {
+			return name
+		}
+	}
+	found := ""
+	bow.Find("meta[http-equiv]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if !strings.EqualFold(attrOr(s, "http-equiv", ""), "content-type") {
+			return true
+		}
+		_, params, err := mime.ParseMediaType(attrOr(s, "content", ""))
+		if err == nil {
+			found = lookupCharset(params["charset"])
+		}
+		return found == ""
+	})
+	if found != "" {
+		return found
+	}
+	return "utf-8"
+}
+
+// encodeEntries returns the entries with their names and values encoded in
+// the given character encoding. Characters which cannot be encoded are sent
+// as HTML character references, the way browsers send them. The value of a
+// hidden field named _charset_ is the name of the encoding.
+func encodeEntries(entries []formEntry, name string) []formEntry {
+	enc, _ := charset.Lookup(name)
+	utf8 := enc == nil || name == "utf-8"
+	var encoder *encoding.Encoder
+	if !utf8 {
+		encoder = encoding.HTMLEscapeUnsupported(enc.NewEncoder())
+	}
+	convert := func(s string) string {
+		if utf8 || s == "" {
+			return s
+		}
+		out, err := encoder.String(s)
+		if err != nil {
+			return s
+		}
+		return out
+	}
+
+	encoded := make([]formEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.typ == "hidden" && strings.EqualFold(e.name, "_charset_") {
+			e.value = name
+		}
+		e.name = convert(e.name)
+		e.value = convert(e.value)
+		if e.file != nil {
+			file := *e.file
+			file.filename = convert(file.filename)
+			e.file = &file
+		}
+		encoded = append(encoded, e)
+	}
+	return encoded
+}
+
+// encodeURLEntries returns the entries in the
+// application/x-www-form-urlencoded format, in order.
+func encodeURLEntries(entries []formEntry) string {
+	var b strings.Builder
+	for i, e := range entries {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(urlencode(e.name))
+		b.WriteByte('=')
+		b.WriteString(urlencode(e.value))
+	}
+	return b.String()
+}
+
+// urlencode escapes the string the way browsers do in form submissions,
+// which differs from url.QueryEscape for "*" and "~".
+func urlencode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ':
+			b.WriteByte('+')
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '*', c == '-', c == '.', c == '_':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
+// encodeTextPlain returns the body of a text/plain form submission.
+func encodeTextPlain(entries []formEntry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(e.name)
+		b.WriteString("=")
+		b.WriteString(e.value)
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,288 @@
+package browser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dataxpe/surf/errors"
+)
+
+// FillReport lists the names which were not filled by Form.Fill.
+type FillReport struct {
+	// NotFound are the names in the filled value which are not the names of
+	// form fields.
+	NotFound []string
+
+	// Unset are the names of the form fields which are not in the filled
+	// value. Hidden fields and buttons are not listed.
+	Unset []string
+}
+
+// Fill sets the values of the form fields from a map with string keys, or
+// from a struct whose fields are tagged with the names of the form fields,
+// e.g. `form:"username"`. Untagged struct fields use the name of the field,
+// and fields tagged `form:"-"` are skipped. The omitempty option skips zero
+// values, e.g. `form:"q,omitempty"`.
+//
+// Bools check and uncheck checkboxes, slices select the options of a
+// multiple select, check checkboxes or fill fields with the same name, and
+// time.Time values are formatted for the type of date input.
+//
+// The names which could not be filled are listed in the returned report.
+// An error is returned when a value is not valid for its field.
+func (f *Form) Fill(v interface{}) (*FillReport, error) {
+	values, err := fillValues(v)
+	if err != nil {
+		return nil, err
+	}
+	report := &FillReport{}
+	filled := make(map[string]bool)
+	for _, fv := range values {
+		if len(f.named(fv.name)) == 0 {
+			report.NotFound = append(report.NotFound, fv.name)
+			continue
+		}
+		if err := f.fill(fv.name, fv.value); err != nil {
+			return report, err
+		}
+		filled[fv.name] = true
+	}
+	for _, c := range f.controls {
+		switch c.typ {
+		case "hidden", "submit", "image", "reset", "button":
+			continue
+		}
+		if c.name != "" && !c.disabled && !filled[c.name] {
+			report.Unset = append(report.Unset, c.name)
+			filled[c.name] = true
+		}
+	}
+	return report, nil
+}
+
+// fill sets the field with the given name to the value.
+func (f *Form) fill(name string, value reflect.Value) error {
+	controls := f.named(name)
+	typ := controls[0].typ
+
+	if value.Kind() == reflect.Bool && typ == "checkbox" {
+		if value.Bool() {
+			return f.Check(name)
+		}
+		return f.Uncheck(name)
+	}
+
+	if (value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8) || value.Kind() == reflect.Array {
+		strs := make([]string, value.Len())
+		for i := range strs {
+			s, err := fillString(value.Index(i), typ)
+			if err != nil {
+				return fillError(name, err)
+			}
+			strs[i] = s
+		}
+		switch typ {
+		case "select-one", "select-multiple":
+			return f.Select(name, strs...)
+		case "checkbox":
+			// Only the checkboxes with the values are checked, and nothing
+			// changes when a value has no checkbox.
+			boxes, matched, err := f.checkboxes(name, strs)
+			if err != nil {
+				return err
+			}
+			for _, c := range boxes {
+				c.checked = false
+			}
+			for _, c := range matched {
+				c.checked = true
+			}
+			return nil
+		}
+		for i, s := range strs {
+			if i < len(controls) {
+				if err := f.setControl(controls[i], s); err != nil {
+					return err
+				}
+			} else {
+				f.Add(name, s)
+			}
+		}
+		return nil
+	}
+
+	s, err := fillString(value, typ)
+	if err != nil {
+		return fillError(name, err)
+	}
+	return f.Set(name, s)
+}
+
+// setControl sets the value of one of the fields with the same name.
+func (f *Form) setControl(c *formControl, value string) error {
+	switch c.typ {
+	case "text", "search", "url", "tel", "email", "password", "number", "range",
+		"date", "month", "week", "time", "datetime-local", "color", "textarea", "hidden":
+		c.value = value
+		return nil
+	}
+	return f.Set(c.name, value)
+}
+
+// fillValue is a name and value to fill in.
+type fillValue struct {
+	name  string
+	value reflect.Value
+}
+
+// fillValues returns the names and values of a map or a struct.
+func fillValues(v interface{}) ([]fillValue, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	var values []fillValue
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, errors.New("Form values must be a map with string keys or a struct, got %s.", rv.Type())
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if value, ok := fillElem(rv.MapIndex(k)); ok {
+				values = append(values, fillValue{k.String(), value})
+			}
+		}
+	case reflect.Struct:
+		values = structValues(rv, values)
+	default:
+		return nil, errors.New("Form values must be a map with string keys or a struct, got %s.", rv.Type())
+	}
+	return values, nil
+}
+
+// structValues appends the names and values of the struct fields.
+func structValues(rv reflect.Value, values []fillValue) []fillValue {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag := sf.Tag.Get("form")
+		if tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		value, ok := fillElem(rv.Field(i))
+		if !ok {
+			continue
+		}
+		if sf.Anonymous && name == "" && value.Kind() == reflect.Struct && value.Type() != timeType {
+			values = structValues(value, values)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isZero(value) {
+			continue
+		}
+		values = append(values, fillValue{name, value})
+	}
+	return values
+}
+
+// fillElem dereferences pointers and interfaces. Returns false for nil
+// values.
+func fillElem(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
+
+// isZero returns true when the value is the zero value of its type.
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// timeType is the type of time.Time values.
+var timeType = reflect.TypeOf(time.Time{})
+
+// fillString formats a value for a field of the given type.
+func fillString(v reflect.Value, typ string) (string, error) {
+	v, ok := fillElem(v)
+	if !ok {
+		return "", nil
+	}
+	if v.Type() == timeType {
+		return formatTime(v.Interface().(time.Time), typ), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return "", fmt.Errorf("values of type %s are not supported", v.Type())
+}
+
+// formatTime formats the time for a date input of the given type.
+func formatTime(t time.Time, typ string) string {
+	switch typ {
+	case "date":
+		return t.Format("2006-01-02")
+	case "month":
+		return t.Format("2006-01")
+	case "week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case "time":
+		if t.Second() != 0 {
+			return t.Format("15:04:05")
+		}
+		return t.Format("15:04")
+	case "datetime-local":
+		if t.Second() != 0 {
+			return t.Format("2006-01-02T15:04:05")
+		}
+		return t.Format("2006-01-02T15:04")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// fillError returns the error for a value which cannot be filled in.
+func fillError(name string, cause error) error {
+	err := errors.NewInvalidFormValue("Cannot fill the field '%s': %s.", name, cause)
+	err.SetFields([]string{name})
+	return err
+}
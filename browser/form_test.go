@@ -2,10 +2,14 @@ package browser
 
 import (
 	"fmt"
+	"io/ioutil"
 	"github.com/headzoo/ut"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+	"github.com/dataxpe/surf/errors"
 	"github.com/dataxpe/surf/jar"
 )
 
@@ -56,3 +60,542 @@ var htmlForm = `<!doctype html>
 	</body>
 </html>
 `
+
+func TestBrowserFormModel(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.RawQuery == "" {
+			fmt.Fprint(w, htmlFormModel)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s|%s", r.Method, r.URL.Path, r.Header.Get("Content-Type"), r.URL.RawQuery+string(b))
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	err := bow.Open(ts.URL + "/page")
+	ut.AssertNil(err)
+	f, err := bow.Form("#model")
+	ut.AssertNil(err)
+	ut.AssertEquals("POST", f.Method())
+	ut.AssertEquals(ts.URL+"/page", f.Action())
+
+	err = f.Submit("")
+	ut.AssertNil(err)
+	ut.AssertEquals("POST /page application/x-www-form-urlencoded|color=blue&lang=go&lang=rust&memo=hi&size=M&go=1&outside=yes", bow.Find("body").Text())
+
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	f, _ = bow.Form("#model")
+	ut.AssertNil(f.Input("size", "L"))
+	ut.AssertNil(f.Input("lang", "c"))
+	ut.AssertNil(f.Input("color", "red"))
+	err = f.Click("alt")
+	ut.AssertNil(err)
+	ut.AssertEquals("GET /alt |color=red&lang=c&lang=go&lang=rust&memo=hi&size=L&alt=&outside=yes", bow.Find("body").Text())
+
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	f, _ = bow.Form("#model")
+	err = f.Click("map")
+	ut.AssertNil(err)
+	ut.AssertContains("&map.x=0&map.y=0&", bow.Find("body").Text())
+
+	err = f.Click("off")
+	ut.AssertNotNil(err)
+	err = f.Click("reset")
+	ut.AssertNotNil(err)
+}
+
+var htmlFormModel = `<!doctype html>
+<html>
+	<body>
+		<form method="post" id="model">
+			<input type="radio" name="color" value="red" checked>
+			<input type="radio" name="color" value="blue" checked>
+			<select name="lang" multiple>
+				<option>c</option>
+				<option selected>go</option>
+				<option value="rust" selected>Rust</option>
+				<option value="java" selected disabled>Java</option>
+			</select>
+			<textarea name="memo">hi</textarea>
+			<select name="size"><option disabled>S</option><option>M</option><option>L</option></select>
+			<input name="secret" value="x" disabled>
+			<fieldset disabled><input name="locked" value="x"></fieldset>
+			<input type="checkbox" name="agree">
+			<button name="go" value="1">Go</button>
+			<button type="reset" name="reset">Reset</button>
+			<button type="button" name="noop">Noop</button>
+			<input type="submit" name="alt" formaction="/alt" formmethod="get">
+			<input type="image" name="map" src="map.png">
+			<input type="submit" name="off" disabled>
+		</form>
+		<input name="outside" value="yes" form="model">
+		<input name="other" value="no" form="elsewhere">
+	</body>
+</html>
+`
+
+func TestFormControls(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormControls)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "<html><body>%s</body></html>", b)
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+
+	fields := f.Fields()
+	ut.AssertEquals(8, len(fields))
+	ut.AssertEquals(Field{Name: "user", Type: "text", Value: "", Required: true}, fields[0])
+	ut.AssertEquals("select-multiple", fields[4].Type)
+	ut.AssertEquals(Option{Value: "go", Label: "Go", Selected: true}, fields[4].Options[0])
+	ut.AssertEquals("b", fields[5].Value)
+	ut.AssertTrue(fields[6].Disabled)
+
+	// Calling Input or Check twice does not uncheck the box.
+	ut.AssertNil(f.Input("news", "yes"))
+	ut.AssertNil(f.Input("news", "yes"))
+	ut.AssertNil(f.Check("tos"))
+	ut.AssertNil(f.Check("tos"))
+	ut.AssertNil(f.Uncheck("news"))
+	ut.AssertNotNil(f.Check("news", "maybe"))
+	ut.AssertNotNil(f.Check("news", "yes", "maybe"))
+	ut.AssertNotNil(f.Check("missing"))
+
+	ut.AssertNil(f.Select("langs", "rust", "c"))
+	ut.AssertNotNil(f.Select("langs", "cobol"))
+	ut.AssertNotNil(f.Select("langs", "java"))
+	ut.AssertNotNil(f.Select("size", "a", "b"))
+	ut.AssertNil(f.Select("size", "a"))
+
+	ut.AssertNil(f.Radio("plan", "pro"))
+	ut.AssertNotNil(f.Radio("plan", "gold"))
+	ut.AssertNotNil(f.Radio("user", "x"))
+
+	ut.AssertNil(f.Set("user", "joe"))
+	ut.AssertNotNil(f.Set("size", "xl"))
+	ut.AssertNotNil(f.Set("nobody", "x"))
+	f.Add("extra", "1")
+	ut.AssertEquals(Field{Name: "extra", Type: "hidden", Value: "1"}, f.Fields()[8])
+
+	err = f.Submit("noclick")
+	ut.AssertNil(err)
+	ut.AssertEquals("user=joe&tos=on&langs=rust&langs=c&size=a&plan=pro&extra=1", bow.Find("body").Text())
+}
+
+var htmlFormControls = `<!doctype html>
+<html>
+	<body>
+		<form method="post">
+			<input name="user" required>
+			<input type="checkbox" name="news" value="yes">
+			<input type="checkbox" name="tos">
+			<input type="radio" name="plan" value="free" checked>
+			<select name="langs" multiple>
+				<option value="go" selected>Go</option>
+				<option>rust</option>
+				<option>c</option>
+				<option disabled>java</option>
+			</select>
+			<select name="size"><option>a</option><option selected>b</option></select>
+			<input name="old" disabled>
+			<input type="radio" name="plan" value="pro">
+		</form>
+	</body>
+</html>
+`
+
+func TestFormValidate(t *testing.T) {
+	ut.Run(t)
+	posts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			posts++
+		}
+		fmt.Fprint(w, htmlFormValidate)
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	bow.SetAttribute(ValidateForms, true)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("#valid")
+	ut.AssertNil(err)
+
+	err = f.Submit("")
+	ut.AssertNotNil(err)
+	var ferr errors.InvalidFormValue
+	ut.AssertTrue(errors.As(err, &ferr))
+	ut.AssertEquals([]string{"user", "tos", "plan", "size"}, ferr.Fields())
+	ut.AssertEquals(0, posts)
+
+	f.Set("user", "jo")
+	f.Check("tos")
+	f.Radio("plan", "free")
+	f.Select("size", "m")
+	f.Set("email", "joe@@example")
+	f.Set("site", "example.com")
+	f.Set("zip", "12a45")
+	f.Set("age", "200")
+	f.Set("price", "1.25")
+	f.Set("day", "2024-02-31")
+	f.Set("start", "09:20")
+	f.Input("color", "purple")
+	err = f.Validate()
+	ut.AssertTrue(errors.As(err, &ferr))
+	ut.AssertEquals([]string{"user", "email", "site", "zip", "age", "price", "day", "start", "color"}, ferr.Fields())
+	ut.AssertContains("'user' is shorter than 3 characters", err.Error())
+	ut.AssertContains("'age' is more than 120", err.Error())
+
+	f.Set("user", "joe")
+	f.Set("email", "joe@example.com")
+	f.Set("site", "https://example.com/")
+	f.Set("zip", "12345")
+	f.Set("age", "42")
+	f.Set("price", "1.5")
+	f.Set("day", "2024-02-29")
+	f.Set("start", "09:00")
+	f.Set("color", "red")
+	ut.AssertNil(f.Validate())
+	ut.AssertNil(f.Submit(""))
+	ut.AssertEquals(1, posts)
+
+	// The novalidate and formnovalidate attributes turn validation off.
+	f, _ = bow.Form("#novalidate")
+	ut.AssertNil(f.Submit(""))
+	f, _ = bow.Form("#valid")
+	ut.AssertNil(f.Click("skip"))
+	ut.AssertEquals(3, posts)
+}
+
+var htmlFormValidate = `<!doctype html>
+<html>
+	<body>
+		<form method="post" id="valid">
+			<input name="user" required minlength="3" maxlength="10">
+			<input type="checkbox" name="tos" required>
+			<input type="radio" name="plan" value="free" required>
+			<input type="radio" name="plan" value="pro">
+			<select name="size" required><option value="">Pick one</option><option>m</option></select>
+			<input type="email" name="email">
+			<input type="url" name="site">
+			<input name="zip" pattern="[0-9]{5}">
+			<input type="number" name="age" min="18" max="120">
+			<input type="number" name="price" step="0.5">
+			<input type="date" name="day">
+			<input type="time" name="start" step="900">
+			<select name="color"><option>red</option></select>
+			<input name="code" value="x" readonly required pattern="[0-9]+">
+			<input type="submit" name="go">
+			<input type="submit" name="skip" formnovalidate>
+		</form>
+		<form method="post" id="novalidate" novalidate>
+			<input name="user" required>
+		</form>
+	</body>
+</html>
+`
+
+func TestFormFill(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormFill)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "<html><body>%s</body></html>", b)
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+
+	type Common struct {
+		Remember bool `form:"remember"`
+	}
+	age := 42
+	search := struct {
+		Common
+		Query    string    `form:"q"`
+		Age      *int      `form:"age"`
+		Langs    []string  `form:"langs"`
+		Tags     []string  `form:"tag"`
+		Born     time.Time `form:"born"`
+		Missing  string    `form:"missing"`
+		Optional string    `form:"opt,omitempty"`
+		Skip     string    `form:"-"`
+		internal string
+	}{
+		Common: Common{Remember: true},
+		Query:  "surf",
+		Age:    &age,
+		Langs:  []string{"go", "c"},
+		Tags:   []string{"a", "b", "c"},
+		Born:   time.Date(1980, 5, 17, 0, 0, 0, 0, time.UTC),
+	}
+	report, err := f.Fill(&search)
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"missing"}, report.NotFound)
+	ut.AssertEquals([]string{"news", "opt"}, report.Unset)
+
+	report, err = f.Fill(map[string]interface{}{"news": false, "langs": []string{"cobol"}})
+	ut.AssertNotNil(err)
+	// The checkboxes are left alone when a value has no checkbox.
+	_, err = f.Fill(map[string]interface{}{"news": []string{"nope"}})
+	ut.AssertNotNil(err)
+	_, err = f.Fill(42)
+	ut.AssertNotNil(err)
+
+	err = f.Submit("noclick")
+	ut.AssertNil(err)
+	ut.AssertEquals("q=surf&age=42&remember=on&news=on&langs=go&langs=c&tag=a&tag=b&born=1980-05-17&opt=&token=t&tag=c", bow.Find("body").Text())
+}
+
+var htmlFormFill = `<!doctype html>
+<html>
+	<body>
+		<form method="post">
+			<input name="q">
+			<input type="number" name="age">
+			<input type="checkbox" name="remember">
+			<input type="checkbox" name="news" checked>
+			<select name="langs" multiple><option>go</option><option>rust</option><option>c</option></select>
+			<input name="tag"><input name="tag">
+			<input type="date" name="born">
+			<input name="opt">
+			<input type="hidden" name="token" value="t">
+		</form>
+	</body>
+</html>
+`
+
+func TestFormCharset(t *testing.T) {
+	ut.Run(t)
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+			fmt.Fprint(w, htmlFormCharset)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		body = r.Header.Get("Content-Type") + "|" + string(b)
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	f, _ := bow.Form("#page")
+	ut.AssertNil(f.Set("q", "Привет"))
+	ut.AssertNil(f.Submit(""))
+	ut.AssertEquals("application/x-www-form-urlencoded|_charset_=windows-1251&q=%CF%F0%E8%E2%E5%F2", body)
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, _ = bow.Form("#accept")
+	ut.AssertNil(f.Set("q", "日本€"))
+	ut.AssertNil(f.Submit(""))
+	ut.AssertEquals("application/x-www-form-urlencoded|q=%93%FA%96%7B%26%238364%3B", body)
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, _ = bow.Form("#plain")
+	ut.AssertNil(f.Set("q", "a b&c"))
+	ut.AssertNil(f.Submit(""))
+	ut.AssertEquals("text/plain|q=a b&c\r\nx=1\r\n", body)
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, _ = bow.Form("#multipart")
+	ut.AssertNil(f.Set("q", "中"))
+	ut.AssertNil(f.Submit(""))
+	ut.AssertContains("\r\n\r\n\xd6\xd0\r\n", body)
+
+	// UTF-16 is sent as UTF-8, and only hidden _charset_ fields are replaced.
+	ut.AssertNil(bow.Open(ts.URL))
+	f, _ = bow.Form("#utf16")
+	ut.AssertNil(f.Set("q", "é"))
+	ut.AssertNil(f.Submit(""))
+	ut.AssertEquals("application/x-www-form-urlencoded|_charset_=&q=%C3%A9", body)
+}
+
+var htmlFormCharset = `<!doctype html>
+<html>
+	<body>
+		<form method="post" id="page">
+			<input type="hidden" name="_charset_">
+			<input name="q">
+		</form>
+		<form method="post" id="accept" accept-charset="bogus shift_jis">
+			<input name="q">
+		</form>
+		<form method="post" id="utf16" accept-charset="utf-16le">
+			<input name="_charset_"><input name="q">
+		</form>
+		<form method="post" id="plain" enctype="text/plain">
+			<input name="q"><input name="x" value="1">
+		</form>
+		<form method="post" id="multipart" enctype="multipart/form-data" accept-charset="gbk">
+			<input name="q">
+		</form>
+	</body>
+</html>
+`
+
+func TestFormOrder(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.RawQuery == "":
+			fmt.Fprint(w, htmlFormOrder)
+		case strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/"):
+			multipartEcho(w, r)
+		default:
+			b, _ := ioutil.ReadAll(r.Body)
+			fmt.Fprintf(w, "<html><body>%s%s</body></html>", r.URL.RawQuery, b)
+		}
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	for _, form := range []string{"#post", "#get"} {
+		ut.AssertNil(bow.Open(ts.URL))
+		f, _ := bow.Form(form)
+		ut.AssertNil(f.Submit(""))
+		ut.AssertEquals("b=1&a=x*y%7Ez+w&b=2&a=3", bow.Find("body").Text())
+	}
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, _ := bow.Form("#multipart")
+	ut.AssertNil(f.Submit(""))
+	ut.AssertEquals("b=1 a=2 b=3", bow.Find("body").Text())
+}
+
+var htmlFormOrder = `<!doctype html>
+<html>
+	<body>
+		<form method="post" id="post">
+			<input name="b" value="1"><input name="a" value="x*y~z w"><input name="b" value="2"><input name="a" value="3">
+		</form>
+		<form id="get">
+			<input name="b" value="1"><input name="a" value="x*y~z w"><input name="b" value="2"><input name="a" value="3">
+		</form>
+		<form method="post" id="multipart" enctype="multipart/form-data">
+			<input name="b" value="1"><input name="a" value="2"><input name="b" value="3">
+		</form>
+	</body>
+</html>
+`
+
+func TestFormBuilder(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+			fmt.Fprint(w, htmlFormBuilder)
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			multipartEcho(w, r)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		cookie := ""
+		if c, err := r.Cookie("session"); err == nil {
+			cookie = c.Value
+		}
+		fmt.Fprintf(w, "<html><body><p id=\"method\">%s %s</p><p id=\"referer\">%s</p><p id=\"cookie\">%s</p><p id=\"data\">%s%s</p></body></html>",
+			r.Method, r.URL.Path, r.Referer(), cookie, r.URL.RawQuery, b)
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	fm := NewFormBuilder(bow).
+		Method("post").
+		Action("/session").
+		Field("user", "joe").
+		Field("tag", "a").
+		Field("tag", "b").
+		Form()
+	ut.AssertEquals("POST", fm.Method())
+	ut.AssertEquals(ts.URL+"/session", fm.Action())
+	ut.AssertNil(fm.Set("user", "jane"))
+	ut.AssertNil(fm.Submit(""))
+	ut.AssertEquals("POST /session", bow.Find("#method").Text())
+	ut.AssertEquals(ts.URL+"/page", bow.Find("#referer").Text())
+	ut.AssertEquals("s1", bow.Find("#cookie").Text())
+	ut.AssertEquals("user=jane&tag=a&tag=b", bow.Find("#data").Text())
+
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	fm = NewFormBuilder(bow).Form()
+	ut.AssertEquals("GET", fm.Method())
+	ut.AssertEquals(ts.URL+"/page", fm.Action())
+
+	page, _ := bow.Form("form")
+	clone := page.Builder().
+		Method("GET").
+		Remove("token").
+		Field("extra", "1").
+		Form()
+	ut.AssertEquals(ts.URL+"/login", clone.Action())
+	ut.AssertNil(clone.Input("user", "joe"))
+	ut.AssertNil(clone.Submit(""))
+	ut.AssertEquals("GET /login", bow.Find("#method").Text())
+	ut.AssertEquals(ts.URL+"/page", bow.Find("#referer").Text())
+	ut.AssertEquals("user=joe&go=Login&extra=1", bow.Find("#data").Text())
+
+	// The page form is not changed by the copy.
+	ut.AssertNil(page.Submit(""))
+	ut.AssertEquals("POST /login", bow.Find("#method").Text())
+	ut.AssertEquals("token=t1&user=&go=Login", bow.Find("#data").Text())
+
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	fm = NewFormBuilder(bow).
+		Method("POST").
+		Action("upload").
+		Enctype("multipart/form-data").
+		Field("title", "Report").
+		File("document", "a.txt", "text/plain", strings.NewReader("A")).
+		Form()
+	ut.AssertNil(fm.File("document", "b.txt", "text/plain", strings.NewReader("B")))
+	ut.AssertNil(fm.Submit(""))
+	ut.AssertEquals("title=Report document=A(a.txt,text/plain) document=B(b.txt,text/plain)", bow.Find("body").Text())
+
+	// Forms are built before a page is loaded.
+	bow = newDefaultTestBrowser()
+	fm = NewFormBuilder(bow).Method("POST").Field("a", "1").Form()
+	ut.AssertEquals("", fm.Action())
+	ut.AssertTrue(errors.Is(fm.Submit(""), errors.ErrLocation))
+	fm = NewFormBuilder(bow).Method("POST").Action("/session").Field("a", "1").Form()
+	ut.AssertTrue(errors.Is(fm.Submit(""), errors.ErrLocation))
+	fm = NewFormBuilder(bow).Method("POST").Action(ts.URL+"/session").Field("a", "1").Form()
+	ut.AssertNil(fm.Submit(""))
+	ut.AssertEquals("POST /session", bow.Find("#method").Text())
+	ut.AssertEquals("a=1", bow.Find("#data").Text())
+}
+
+var htmlFormBuilder = `<!doctype html>
+<html>
+	<body>
+		<form method="post" action="/login">
+			<input type="hidden" name="token" value="t1">
+			<input name="user">
+			<input type="submit" name="go" value="Login">
+		</form>
+	</body>
+</html>
+`
@@ -0,0 +1,286 @@
+package browser
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dataxpe/surf/errors"
+)
+
+// emailPattern matches a valid e-mail address, as defined by the HTML
+// standard.
+var emailPattern = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// Validate checks the values of the form fields against the constraints in
+// the page: the required, minlength, maxlength, pattern, min, max and step
+// attributes, the e-mail, URL, number and date input types, and the options
+// of selects.
+//
+// Returns an errors.InvalidFormValue listing the fields which are not valid.
+func (f *Form) Validate() error {
+	var names, problems []string
+	checkedRadios := make(map[string]bool)
+	for _, c := range f.controls {
+		if c.typ == "radio" && c.checked {
+			checkedRadios[c.name] = true
+		}
+	}
+	reported := make(map[string]bool)
+	for _, c := range f.controls {
+		problem := f.validateControl(c, checkedRadios)
+		if problem == "" || reported[c.name] {
+			continue
+		}
+		reported[c.name] = true
+		names = append(names, c.name)
+		problems = append(problems, fmt.Sprintf("'%s' %s", c.name, problem))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	err := errors.NewInvalidFormValue(
+		"Form is not valid: %s.", strings.Join(problems, "; "))
+	err.SetFields(names)
+	return err
+}
+
+// validates returns true when the form is validated before it is submitted
+// with the given submit button.
+func (f *Form) validates(submitter *formControl) bool {
+	if !f.bow.GetAttribute(ValidateForms) {
+		return false
+	}
+	if _, ok := f.selection.Attr("novalidate"); ok {
+		return false
+	}
+	if submitter != nil {
+		if _, ok := submitter.node.Attr("formnovalidate"); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// validateControl returns the reason the value of the control is not valid,
+// or an empty string when it is valid.
+func (f *Form) validateControl(c *formControl, checkedRadios map[string]bool) string {
+	if c.disabled || c.node == nil {
+		return ""
+	}
+	if _, ok := c.node.Attr("readonly"); ok && c.typ != "select-one" && c.typ != "select-multiple" {
+		return ""
+	}
+	switch c.typ {
+	case "hidden", "submit", "image", "reset", "button":
+		return ""
+	case "checkbox":
+		if c.required && !c.checked {
+			return "must be checked"
+		}
+		return ""
+	case "radio":
+		if c.required && !checkedRadios[c.name] {
+			return "must have an option selected"
+		}
+		return ""
+	case "file":
+		if c.required && len(c.files) == 0 {
+			return "requires a file"
+		}
+		return ""
+	case "select-one", "select-multiple":
+		selected := false
+		for _, o := range c.options {
+			if !o.selected {
+				continue
+			}
+			if o.added || o.disabled {
+				return fmt.Sprintf("has no option with the value '%s'", o.value)
+			}
+			if o.value != "" {
+				selected = true
+			}
+		}
+		if c.required && !selected {
+			return "must have an option selected"
+		}
+		return ""
+	}
+
+	value := c.value
+	if value == "" {
+		if c.required {
+			return "is required"
+		}
+		return ""
+	}
+	length := utf8.RuneCountInString(value)
+	if max, err := strconv.Atoi(attrOr(c.node, "maxlength", "")); err == nil && length > max {
+		return fmt.Sprintf("is longer than %d characters", max)
+	}
+	if min, err := strconv.Atoi(attrOr(c.node, "minlength", "")); err == nil && length < min {
+		return fmt.Sprintf("is shorter than %d characters", min)
+	}
+
+	switch c.typ {
+	case "email":
+		for _, addr := range emailAddresses(c, value) {
+			if !emailPattern.MatchString(addr) {
+				return "is not a valid e-mail address"
+			}
+		}
+	case "url":
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			return "is not a valid URL"
+		}
+	case "number", "range", "date", "month", "week", "time", "datetime-local":
+		return validateNumeric(c, value)
+	}
+
+	if pattern, ok := c.node.Attr("pattern"); ok && hasPattern(c.typ) {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err == nil {
+			for _, v := range emailAddresses(c, value) {
+				if !re.MatchString(v) {
+					return fmt.Sprintf("does not match the pattern '%s'", pattern)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// hasPattern returns true when the pattern attribute applies to inputs of
+// the given type.
+func hasPattern(typ string) bool {
+	switch typ {
+	case "text", "search", "url", "tel", "email", "password":
+		return true
+	}
+	return false
+}
+
+// emailAddresses splits the value of an e-mail input accepting multiple
+// addresses. Returns the value of other inputs.
+func emailAddresses(c *formControl, value string) []string {
+	if _, ok := c.node.Attr("multiple"); !ok || c.typ != "email" {
+		return []string{value}
+	}
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		addrs = append(addrs, strings.TrimSpace(addr))
+	}
+	return addrs
+}
+
+// validateNumeric checks the value of a number or date input against its
+// min, max and step attributes.
+func validateNumeric(c *formControl, value string) string {
+	n, ok := numericValue(c.typ, value)
+	if !ok {
+		return "is not a valid " + strings.Replace(c.typ, "-", " ", -1)
+	}
+	min, hasMin := numericValue(c.typ, attrOr(c.node, "min", ""))
+	if hasMin && n < min {
+		return fmt.Sprintf("is less than %s", attrOr(c.node, "min", ""))
+	}
+	if max, ok := numericValue(c.typ, attrOr(c.node, "max", "")); ok && n > max {
+		return fmt.Sprintf("is more than %s", attrOr(c.node, "max", ""))
+	}
+
+	step, scale := 1.0, 1.0
+	switch c.typ {
+	case "time", "datetime-local":
+		step = 60
+	case "date":
+		scale = 86400
+	case "week":
+		scale = 7 * 86400
+	}
+	if s, ok := c.node.Attr("step"); ok {
+		if strings.EqualFold(s, "any") {
+			return ""
+		}
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 {
+			step = v
+		}
+	}
+	// The default step base of weeks is the Monday of 1970-W01.
+	base := 0.0
+	if c.typ == "week" {
+		base = -3 * 86400
+	}
+	if hasMin {
+		base = min
+	} else if def, ok := numericValue(c.typ, attrOr(c.node, "value", "")); ok && c.typ != "range" {
+		base = def
+	}
+	steps := (n - base) / (step * scale)
+	if math.Abs(steps-math.Round(steps)) > 1e-9 {
+		return fmt.Sprintf("is not a multiple of the step %v", step)
+	}
+	return ""
+}
+
+// numericValue returns the number represented by the value of a number or
+// date input. Dates and times are seconds since the Unix epoch, months are
+// months since January 1970.
+func numericValue(typ, value string) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	var t time.Time
+	var err error
+	switch typ {
+	case "number", "range":
+		n, err := strconv.ParseFloat(value, 64)
+		return n, err == nil && !math.IsInf(n, 0) && !math.IsNaN(n)
+	case "date":
+		t, err = time.Parse("2006-01-02", value)
+	case "month":
+		t, err = time.Parse("2006-01", value)
+		if err != nil {
+			return 0, false
+		}
+		return float64((t.Year()-1970)*12 + int(t.Month()) - 1), true
+	case "week":
+		var year, week int
+		if _, err := fmt.Sscanf(value, "%4d-W%2d", &year, &week); err != nil || week < 1 || week > 53 {
+			return 0, false
+		}
+		// Week 1 is the week containing January 4th.
+		jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+		offset := (int(jan4.Weekday()) + 6) % 7
+		t = jan4.AddDate(0, 0, -offset+(week-1)*7)
+	case "time":
+		t, err = parseTime(value, "15:04", "15:04:05", "15:04:05.999")
+	case "datetime-local":
+		t, err = parseTime(strings.Replace(value, " ", "T", 1),
+			"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02T15:04:05.999")
+	default:
+		return 0, false
+	}
+	if err != nil {
+		return 0, false
+	}
+	return float64(t.Unix()) + float64(t.Nanosecond())/float64(time.Second), true
+}
+
+// parseTime parses the value with the first layout that matches.
+func parseTime(value string, layouts ...string) (time.Time, error) {
+	var t time.Time
+	var err error
+	for _, layout := range layouts {
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
@@ -0,0 +1,210 @@
+package browser
+
+import (
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Multipart is a multipart/form-data request body made of text fields and
+// files, in the order they are added. Files are read while the request is
+// sent, so large files are never held in memory.
+type Multipart struct {
+	parts []*multipartPart
+}
+
+// multipartPart is a text field or a file of a multipart body.
+type multipartPart struct {
+	name        string
+	value       string
+	filename    string
+	contentType string
+	reader      io.Reader
+}
+
+// NewMultipart creates and returns a new *Multipart type.
+func NewMultipart() *Multipart {
+	return &Multipart{}
+}
+
+// Field adds a text field to the body.
+func (m *Multipart) Field(name, value string) *Multipart {
+	m.parts = append(m.parts, &multipartPart{name: name, value: value})
+	return m
+}
+
+// File adds a file to the body, whose contents are read from r. The content
+// type defaults to "application/octet-stream".
+func (m *Multipart) File(name, filename, contentType string, r io.Reader) *Multipart {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if r == nil {
+		r = strings.NewReader("")
+	}
+	m.parts = append(m.parts, &multipartPart{
+		name:        name,
+		filename:    filename,
+		contentType: contentType,
+		reader:      r,
+	})
+	return m
+}
+
+// PostMultipartParts requests the given URL using the POST method with the
+// multipart body. The Content-Length header is sent when the size of every
+// file is known, e.g. for an *os.File, and the body is chunked otherwise.
+func (bow *Browser) PostMultipartParts(u string, m *Multipart, ref *url.URL) error {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	boundary := multipart.NewWriter(nil).Boundary()
+	body := &multipartReader{parts: m.parts, boundary: boundary}
+	req, err := bow.buildRequest("POST", ur.String(), ref, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	if n, ok := m.length(boundary); ok {
+		req.ContentLength = n
+	}
+	return bow.httpRequest(req)
+}
+
+// length returns the size of the body written with the given boundary, and
+// false when the size of a file is not known.
+func (m *Multipart) length(boundary string) (int64, bool) {
+	cw := &countWriter{}
+	w := multipart.NewWriter(cw)
+	w.SetBoundary(boundary)
+	for _, p := range m.parts {
+		pw, err := w.CreatePart(p.header())
+		if err != nil {
+			return 0, false
+		}
+		if p.reader == nil {
+			io.WriteString(pw, p.value)
+			continue
+		}
+		n, ok := readerSize(p.reader)
+		if !ok {
+			return 0, false
+		}
+		cw.n += n
+	}
+	w.Close()
+	return cw.n, true
+}
+
+// header returns the MIME header of the part, escaping the names the way
+// browsers do.
+func (p *multipartPart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	disposition := `form-data; name="` + escapeMultipartName(p.name) + `"`
+	if p.reader != nil {
+		disposition += `; filename="` + escapeMultipartName(p.filename) + `"`
+		h.Set("Content-Type", p.contentType)
+	}
+	h.Set("Content-Disposition", disposition)
+	return h
+}
+
+// multipartEscaper escapes the names and file names of the parts.
+var multipartEscaper = strings.NewReplacer("\n", "%0A", "\r", "%0D", `"`, "%22")
+
+// escapeMultipartName escapes a field name or a file name.
+func escapeMultipartName(s string) string {
+	return multipartEscaper.Replace(s)
+}
+
+// multipartReader streams the multipart body. The parts are written by a
+// goroutine started by the first read, so nothing is left running when the
+// request is never sent.
+type multipartReader struct {
+	parts    []*multipartPart
+	boundary string
+	once     sync.Once
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+}
+
+// Read implements the io.Reader interface.
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.start()
+	return r.pr.Read(p)
+}
+
+// Close implements the io.Closer interface.
+func (r *multipartReader) Close() error {
+	r.start()
+	return r.pr.Close()
+}
+
+// start creates the pipe and starts writing the parts to it.
+func (r *multipartReader) start() {
+	r.once.Do(func() {
+		r.pr, r.pw = io.Pipe()
+		go r.write()
+	})
+}
+
+// write writes the parts to the pipe.
+func (r *multipartReader) write() {
+	w := multipart.NewWriter(r.pw)
+	w.SetBoundary(r.boundary)
+	for _, p := range r.parts {
+		pw, err := w.CreatePart(p.header())
+		if err != nil {
+			r.pw.CloseWithError(err)
+			return
+		}
+		if p.reader == nil {
+			_, err = io.WriteString(pw, p.value)
+		} else {
+			_, err = io.Copy(pw, p.reader)
+		}
+		if err != nil {
+			r.pw.CloseWithError(err)
+			return
+		}
+	}
+	r.pw.CloseWithError(w.Close())
+}
+
+// countWriter counts the bytes written to it.
+type countWriter struct {
+	n int64
+}
+
+// Write implements the io.Writer interface.
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// readerSize returns the number of bytes left in the reader, and false when
+// it cannot be known without reading.
+func readerSize(r io.Reader) (int64, bool) {
+	switch rr := r.(type) {
+	case interface{ Len() int }:
+		return int64(rr.Len()), true
+	case io.Seeker:
+		cur, err := rr.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := rr.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := rr.Seek(cur, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - cur, true
+	}
+	return 0, false
+}
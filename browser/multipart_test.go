@@ -0,0 +1,93 @@
+package browser
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+// multipartEcho writes the parts of a multipart request as a page.
+func multipartEcho(w http.ResponseWriter, r *http.Request) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		b, _ := ioutil.ReadAll(p)
+		if p.FileName() != "" || p.Header.Get("Content-Type") != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s(%s,%s)", p.FormName(), b, p.FileName(), p.Header.Get("Content-Type")))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=%s", p.FormName(), b))
+		}
+	}
+	fmt.Fprintf(w, "<html><title>%d</title><body>%s</body></html>", r.ContentLength, strings.Join(parts, " "))
+}
+
+func TestFormFile(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormFile)
+			return
+		}
+		multipartEcho(w, r)
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+	err = f.File("doc", "report.txt", "text/plain", strings.NewReader("hello"))
+	ut.AssertNil(err)
+	ut.AssertNotNil(f.File("token", "x", "", strings.NewReader("")))
+	err = f.Submit("")
+	ut.AssertNil(err)
+	ut.AssertEquals("token=abc doc=hello(report.txt,text/plain) empty=(,application/octet-stream) go=", bow.Find("body").Text())
+	ut.AssertFalse(bow.Title() == "-1")
+}
+
+func TestPostMultipartParts(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(multipartEcho))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	m := NewMultipart().
+		Field("name", "joe").
+		File("upload", `a "b".bin`, "", io.MultiReader(strings.NewReader("1234")))
+	err := bow.PostMultipartParts(ts.URL, m, nil)
+	ut.AssertNil(err)
+	ut.AssertEquals("-1", bow.Title())
+	ut.AssertEquals(`name=joe upload=1234(a %22b%22.bin,application/octet-stream)`, bow.Find("body").Text())
+}
+
+var htmlFormFile = `<!doctype html>
+<html>
+	<body>
+		<form method="post" enctype="multipart/form-data">
+			<input type="hidden" name="token" value="abc">
+			<input type="file" name="doc">
+			<input type="file" name="empty">
+			<input type="submit" name="go">
+		</form>
+	</body>
+</html>
+`
@@ -0,0 +1,150 @@
+package browser
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dataxpe/surf/errors"
+)
+
+// oauth2ExpiryDelta is how long before it expires a token is replaced.
+const oauth2ExpiryDelta = 10 * time.Second
+
+// OAuth2 gets access tokens from an OAuth2 token endpoint with the client
+// credentials grant, or with the refresh token grant when a refresh token is
+// set. Tokens are cached until they expire, or until the server rejects them.
+type OAuth2 struct {
+	// TokenURL is the URL of the token endpoint.
+	TokenURL string
+
+	// ClientID and ClientSecret identify the client.
+	ClientID     string
+	ClientSecret string
+
+	// Scopes are the scopes requested.
+	Scopes []string
+
+	// RefreshToken is used to get access tokens when set. It is replaced
+	// when the server sends a new refresh token.
+	RefreshToken string
+
+	// ClientAuthInBody sends the client id and secret in the request body
+	// instead of the Authorization header.
+	ClientAuthInBody bool
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// SetOAuth2 sets the OAuth2 authenticator used to get tokens for the hosts
+// matching the pattern, or removes it when o is nil. The tokens are requested
+// with the transport and proxy settings of the browser.
+//
+// The pattern is matched the same way as the SetCredentials pattern.
+func (bow *Browser) SetOAuth2(pattern string, o *OAuth2) {
+	if o == nil {
+		bow.SetCredentials(pattern, nil)
+		return
+	}
+	bow.SetCredentials(pattern, &Credentials{OAuth2: o})
+}
+
+// accessToken returns the cached access token, or gets a new one using the
+// given transport.
+func (o *OAuth2) accessToken(req *http.Request, rt http.RoundTripper) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.token != "" && (o.expiry.IsZero() || time.Now().Add(oauth2ExpiryDelta).Before(o.expiry)) {
+		return o.token, nil
+	}
+	return o.fetch(req, rt)
+}
+
+// invalidate forgets the access token when the server rejected it.
+func (o *OAuth2) invalidate(token string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.token == token {
+		o.token = ""
+	}
+}
+
+// fetch requests a new access token from the token endpoint.
+func (o *OAuth2) fetch(orig *http.Request, rt http.RoundTripper) (string, error) {
+	form := url.Values{}
+	if o.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", o.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	if o.ClientAuthInBody {
+		form.Set("client_id", o.ClientID)
+		form.Set("client_secret", o.ClientSecret)
+	}
+
+	req, err := http.NewRequest("POST", o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(orig.Context())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if ua := orig.Header.Get("User-Agent"); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	if !o.ClientAuthInBody {
+		req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return "", requestError(err, req)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", requestError(err, req)
+	}
+
+	var tok struct {
+		AccessToken      string      `json:"access_token"`
+		TokenType        string      `json:"token_type"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		RefreshToken     string      `json:"refresh_token"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil && resp.StatusCode == 200 {
+		return "", errors.Wrap(err, "OAuth2 token response from %s is not valid.", o.TokenURL)
+	}
+	if resp.StatusCode != 200 || tok.AccessToken == "" {
+		msg := tok.Error
+		if tok.ErrorDescription != "" {
+			msg += ": " + tok.ErrorDescription
+		}
+		if msg == "" {
+			msg = resp.Status
+		}
+		return "", errors.New("OAuth2 token request to %s failed: %s", o.TokenURL, msg)
+	}
+
+	o.token = tok.AccessToken
+	o.expiry = time.Time{}
+	if secs, err := tok.ExpiresIn.Int64(); err == nil && secs > 0 {
+		o.expiry = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	if tok.RefreshToken != "" {
+		o.RefreshToken = tok.RefreshToken
+	}
+	return o.token, nil
+}
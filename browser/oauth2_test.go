@@ -0,0 +1,74 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestOAuth2(t *testing.T) {
+	ut.Run(t)
+	issued := 0
+	valid := ""
+	var grants []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			id, secret, _ := r.BasicAuth()
+			r.ParseForm()
+			grants = append(grants, r.PostForm.Get("grant_type")+" "+r.PostForm.Get("refresh_token"))
+			if id != "client" || secret != "s3cret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error":"invalid_client"}`)
+				return
+			}
+			issued++
+			valid = fmt.Sprintf("token%d", issued)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":3600,"refresh_token":"r%d"}`, valid, issued)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "<html><title>%s</title></html>", valid)
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	o := &OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "client",
+		ClientSecret: "s3cret",
+	}
+	bow.SetOAuth2(ts.Listener.Addr().String(), o)
+	err := bow.Open(ts.URL + "/api")
+	ut.AssertNil(err)
+	ut.AssertEquals("token1", bow.Title())
+
+	// The token is cached.
+	err = bow.Open(ts.URL + "/api")
+	ut.AssertNil(err)
+	ut.AssertEquals("token1", bow.Title())
+	ut.AssertEquals(1, issued)
+
+	// The token is refreshed when the server rejects it.
+	valid = "revoked"
+	err = bow.Open(ts.URL + "/api")
+	ut.AssertNil(err)
+	ut.AssertEquals("token2", bow.Title())
+	ut.AssertEquals([]string{"client_credentials ", "refresh_token r1"}, grants)
+	ut.AssertEquals("r2", o.RefreshToken)
+
+	bow.SetOAuth2(ts.Listener.Addr().String(), &OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "client",
+		ClientSecret: "wrong",
+	})
+	err = bow.Open(ts.URL + "/api")
+	ut.AssertNotNil(err)
+	ut.AssertContains("invalid_client", err.Error())
+}
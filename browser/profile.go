@@ -0,0 +1,113 @@
+package browser
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/andybalholm/brotli"
+	"github.com/dataxpe/surf/agent"
+	"github.com/dataxpe/surf/jar"
+)
+
+// SetProfile sets the browser profile whose headers are sent with each
+// request, in the order of the profile, and sets the user agent to the user
+// agent of the profile. Request headers added with AddRequestHeader override
+// the profile headers.
+func (bow *Browser) SetProfile(p *agent.Profile) {
+	bow.profile = p
+	if p != nil {
+		bow.userAgent = p.UserAgent()
+	}
+	bow.applyTransport()
+}
+
+// GetProfile gets the browser profile whose headers are sent.
+func (bow *Browser) GetProfile() *agent.Profile {
+	return bow.profile
+}
+
+// DownloadAsset copies the asset to the given writer. Unlike the
+// DownloadAsset function, the asset is requested with the cookies and
+// headers of the browser, the way a browser fetches the assets of the
+// current page.
+func (bow *Browser) DownloadAsset(asset Downloadable, out io.Writer) (int64, error) {
+	if bow.client == nil {
+		bow.client = bow.buildClient()
+	}
+	rt := agent.Navigation
+	switch asset.AssetType() {
+	case ImageAsset:
+		rt = agent.ImageRequest
+	case StylesheetAsset:
+		rt = agent.StylesheetRequest
+	case ScriptAsset:
+		rt = agent.ScriptRequest
+	}
+	ref := bow.Url()
+	req, err := bow.buildRequestFor(rt, "GET", asset.Url().String(), ref, nil)
+	if err != nil {
+		return 0, err
+	}
+	if pj, ok := bow.client.Jar.(*jar.PolicyJar); ok {
+		pj.SetFirstParty(ref)
+	}
+	resp, err := bow.client.Do(req)
+	if err != nil {
+		return 0, requestError(err, req)
+	}
+	defer resp.Body.Close()
+
+	reader, err := decodeBody(resp)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(out, reader)
+}
+
+// profileHeaders returns the headers of the browser profile for the given
+// kind of request made from the page at ref.
+func (bow *Browser) profileHeaders(rt agent.RequestType, req *http.Request, ref *url.URL) http.Header {
+	if bow.profile == nil {
+		return make(http.Header)
+	}
+	h := bow.profile.Headers(rt, fetchSite(req.URL, ref))
+	if rt == agent.FormSubmission || rt == agent.FetchRequest && req.Method != "GET" && req.Method != "HEAD" {
+		if ref != nil {
+			h.Set("Origin", ref.Scheme+"://"+ref.Host)
+		} else {
+			h.Set("Origin", "null")
+		}
+	}
+	return h
+}
+
+// fetchSite returns the Sec-Fetch-Site value of a request for u made from
+// the page at ref.
+func fetchSite(u, ref *url.URL) string {
+	switch {
+	case ref == nil || ref.Host == "":
+		return agent.SiteNone
+	case ref.Scheme == u.Scheme && ref.Host == u.Host:
+		return agent.SiteSameOrigin
+	case jar.Site(ref.Hostname()) == jar.Site(u.Hostname()):
+		return agent.SiteSameSite
+	}
+	return agent.SiteCrossSite
+}
+
+// decodeBody returns a reader of the response body decoded according to the
+// Content-Encoding header.
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		return flate.NewReader(resp.Body), nil
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,62 @@
+package browser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dataxpe/surf/agent"
+	"github.com/headzoo/ut"
+)
+
+func TestProfileHeaders(t *testing.T) {
+	ut.Run(t)
+	headers := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		if r.URL.Path == "/image.png" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			fmt.Fprint(gz, "PNG")
+			gz.Close()
+			return
+		}
+		fmt.Fprint(w, `<html><form method="post" action="/submit"></form><img src="/image.png"></html>`)
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	bow.SetProfile(agent.ChromeProfile())
+	bow.AddRequestHeader("Accept-Language", "de-DE,de;q=0.9")
+	ut.AssertEquals(agent.ChromeProfile().UserAgent(), bow.GetUserAgent())
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	h := <-headers
+	ut.AssertEquals(bow.GetUserAgent(), h.Get("User-Agent"))
+	ut.AssertEquals("none", h.Get("Sec-Fetch-Site"))
+	ut.AssertEquals("document", h.Get("Sec-Fetch-Dest"))
+	ut.AssertEquals("de-DE,de;q=0.9", h.Get("Accept-Language"))
+	ut.AssertEquals(`"Windows"`, h.Get("Sec-Ch-Ua-Platform"))
+
+	form, err := bow.Form("form")
+	ut.AssertNil(err)
+	err = form.Submit("")
+	ut.AssertNil(err)
+	h = <-headers
+	ut.AssertEquals("same-origin", h.Get("Sec-Fetch-Site"))
+	ut.AssertEquals("max-age=0", h.Get("Cache-Control"))
+	ut.AssertEquals(ts.URL, h.Get("Origin"))
+
+	buff := &bytes.Buffer{}
+	_, err = bow.DownloadAsset(bow.Images()[0], buff)
+	ut.AssertNil(err)
+	h = <-headers
+	ut.AssertEquals("image", h.Get("Sec-Fetch-Dest"))
+	ut.AssertEquals("no-cors", h.Get("Sec-Fetch-Mode"))
+	ut.AssertEquals(ts.URL+"/submit", h.Get("Referer"))
+	ut.AssertEquals("PNG", buff.String())
+}
@@ -0,0 +1,157 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dataxpe/surf/errors"
+	"github.com/dataxpe/surf/jar"
+)
+
+// DefaultMaxRedirects is the number of redirects followed when the redirect
+// policy does not set MaxHops.
+var DefaultMaxRedirects = 10
+
+// DefaultStripHeaders are the request headers removed from a redirect to a
+// different origin when the redirect policy does not set StripHeaders.
+var DefaultStripHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// credentialHeaders are the request headers always removed from a redirect
+// to a different origin, whatever the redirect policy strips.
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// RedirectPolicy controls which redirects a Browser follows when the
+// FollowRedirects attribute is set.
+type RedirectPolicy struct {
+	// MaxHops is the maximum number of redirects followed for a single
+	// request. DefaultMaxRedirects is used when zero.
+	MaxHops int
+
+	// SameHostOnly refuses redirects to a host or port other than the host
+	// of the original request.
+	SameHostOnly bool
+
+	// AllowedHosts refuses redirects to hosts not in the list when it is not
+	// empty. An entry matches the host itself and all of its subdomains.
+	AllowedHosts []string
+
+	// StripHeaders are the request headers removed when a redirect leads to
+	// a different origin than the original request. DefaultStripHeaders is
+	// used when nil. The Authorization, Cookie and Proxy-Authorization
+	// headers are removed as well, even when they are not listed.
+	StripHeaders []string
+
+	// OnRedirect is called before each hop is followed. The request may be
+	// modified, and returning an error stops the redirect chain.
+	OnRedirect func(req *http.Request, via []*http.Request) error
+}
+
+// SetRedirectPolicy sets the policy used to follow redirects.
+func (bow *Browser) SetRedirectPolicy(p *RedirectPolicy) {
+	bow.redirectPolicy = p
+}
+
+// GetRedirectPolicy gets the policy used to follow redirects.
+func (bow *Browser) GetRedirectPolicy() *RedirectPolicy {
+	if bow.redirectPolicy == nil {
+		return &RedirectPolicy{}
+	}
+	return bow.redirectPolicy
+}
+
+// shouldRedirect is used as the value to http.Client.CheckRedirect.
+func (bow *Browser) shouldRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > 0 && req.Response != nil {
+		bow.redirects = append(bow.redirects, &jar.Redirect{
+			URL:        via[len(via)-1].URL,
+			StatusCode: req.Response.StatusCode,
+			Location:   req.URL,
+		})
+	}
+	if !bow.attributes[FollowRedirects] {
+		return redirectError(req, "Redirects are disabled. Cannot follow '%s'.", req.URL.String())
+	}
+	if len(via) == 0 {
+		return nil
+	}
+
+	p := bow.GetRedirectPolicy()
+	max := p.MaxHops
+	if max == 0 {
+		max = DefaultMaxRedirects
+	}
+	if len(via) > max {
+		return redirectError(req, "Stopped after %d redirects. Cannot follow '%s'.", max, req.URL.String())
+	}
+	first := via[0].URL
+	if p.SameHostOnly && !strings.EqualFold(req.URL.Host, first.Host) {
+		return redirectError(req, "Redirect to another host is not allowed. Cannot follow '%s'.", req.URL.String())
+	}
+	if len(p.AllowedHosts) > 0 && !hostAllowed(req.URL.Hostname(), p.AllowedHosts) {
+		return redirectError(req, "Host is not in the allowed hosts. Cannot follow '%s'.", req.URL.String())
+	}
+
+	// The cookie jar adds the cookies of every hop itself, and the client
+	// only keeps the credentials on redirects to the same domain.
+	for attr, val := range via[0].Header {
+		if !isCredentialHeader(attr) && !hasHeader(req.Header, attr) {
+			req.Header[attr] = val
+		}
+	}
+	if !sameOrigin(req.URL, first) {
+		strip := p.StripHeaders
+		if strip == nil {
+			strip = DefaultStripHeaders
+		}
+		for _, name := range strip {
+			delHeader(req.Header, name)
+		}
+		for _, name := range credentialHeaders {
+			delHeader(req.Header, name)
+		}
+	}
+	bow.stripCSRFHeaders(req, first)
+
+	if p.OnRedirect != nil {
+		return p.OnRedirect(req, via)
+	}
+	return nil
+}
+
+// isCredentialHeader returns true when the header is one of the
+// credentialHeaders.
+func isCredentialHeader(name string) bool {
+	for _, h := range credentialHeaders {
+		if strings.EqualFold(name, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectError creates an errors.Location for the given redirect request.
+func redirectError(req *http.Request, msg string, a ...interface{}) error {
+	err := errors.NewLocation(msg, a...)
+	err.URL = req.URL.String()
+	err.Method = req.Method
+	return err
+}
+
+// hostAllowed returns true when host equals one of the allowed hosts, or is
+// a subdomain of one.
+func hostAllowed(host string, allowed []string) bool {
+	host = strings.ToLower(host)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin returns true when both URLs have the same scheme, host and port.
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && strings.EqualFold(a.Host, b.Host)
+}
@@ -0,0 +1,118 @@
+package browser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dataxpe/surf/errors"
+	"github.com/headzoo/ut"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	ut.Run(t)
+	var auth, cookie string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		cookie = r.Header.Get("Cookie")
+		w.Write([]byte("<html><title>Other</title></html>"))
+	}))
+	defer other.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.Redirect(w, r, "/step", http.StatusFound)
+		case "/step":
+			http.Redirect(w, r, other.URL+"/landing", http.StatusMovedPermanently)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	bow.AddRequestHeader("Authorization", "Bearer secret")
+	bow.AddRequestHeader("Cookie", "session=secret")
+	hops := 0
+	bow.SetRedirectPolicy(&RedirectPolicy{
+		OnRedirect: func(req *http.Request, via []*http.Request) error {
+			hops++
+			return nil
+		},
+	})
+	err := bow.Open(ts.URL + "/login")
+	ut.AssertNil(err)
+	ut.AssertEquals("Other", bow.Title())
+	ut.AssertEquals("", auth)
+	ut.AssertEquals("", cookie)
+	ut.AssertEquals(2, hops)
+
+	redirects := bow.GetState().Redirects
+	ut.AssertEquals(2, len(redirects))
+	ut.AssertEquals(ts.URL+"/login", redirects[0].URL.String())
+	ut.AssertEquals(302, redirects[0].StatusCode)
+	ut.AssertEquals(ts.URL+"/step", redirects[0].Location.String())
+	ut.AssertEquals(301, redirects[1].StatusCode)
+	ut.AssertEquals(other.URL+"/landing", redirects[1].Location.String())
+
+	bow.SetRedirectPolicy(&RedirectPolicy{SameHostOnly: true})
+	err = bow.Open(ts.URL + "/login")
+	ut.AssertTrue(errors.Is(err, errors.ErrLocation))
+	ut.AssertEquals(301, bow.StatusCode())
+
+	bow.SetRedirectPolicy(&RedirectPolicy{AllowedHosts: []string{"example.com"}})
+	err = bow.Open(ts.URL + "/login")
+	ut.AssertTrue(errors.Is(err, errors.ErrLocation))
+
+	bow.SetRedirectPolicy(&RedirectPolicy{MaxHops: 3})
+	err = bow.Open(ts.URL + "/loop")
+	ut.AssertTrue(errors.Is(err, errors.ErrLocation))
+	ut.AssertEquals(4, len(bow.GetState().Redirects))
+}
+
+func TestRedirectHeaders(t *testing.T) {
+	ut.Run(t)
+	var auth, cookies []string
+	var proxyAuth string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range r.Header {
+			if strings.EqualFold(name, "Authorization") {
+				auth = append(auth, values...)
+			}
+		}
+		proxyAuth = r.Header.Get("Proxy-Authorization")
+		w.Write([]byte("<html><title>Other</title></html>"))
+	}))
+	defer other.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+			http.Redirect(w, r, "/step", http.StatusFound)
+		case "/step":
+			cookies = r.Header["Cookie"]
+			http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	bow.AddRequestHeader("authorization", "Bearer secret")
+	ut.AssertNil(bow.Open(ts.URL + "/login"))
+	ut.AssertEquals("Other", bow.Title())
+	ut.AssertEquals(0, len(auth))
+	ut.AssertEquals(1, len(cookies))
+	ut.AssertEquals("session=1", cookies[0])
+
+	// The credentials are removed even when the policy does not list them.
+	auth = nil
+	bow.SetRedirectPolicy(&RedirectPolicy{StripHeaders: []string{"X-Api-Key"}})
+	bow.AddRequestHeader("Proxy-Authorization", "Basic secret")
+	ut.AssertNil(bow.Open(ts.URL + "/login"))
+	ut.AssertEquals("Other", bow.Title())
+	ut.AssertEquals(0, len(auth))
+	ut.AssertEquals("", proxyAuth)
+}
@@ -0,0 +1,829 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Diggernaut/goquery"
+	"github.com/robertkrimen/otto"
+	"golang.org/x/net/html"
+)
+
+// ScriptTimeout is the longest time a script of a page, or a function run
+// by a timer, runs before it is stopped.
+var ScriptTimeout = 2 * time.Second
+
+// maxScriptTimers is the number of timers run after the scripts of a page.
+const maxScriptTimers = 100
+
+// errScriptTimeout stops a script which runs longer than ScriptTimeout.
+var errScriptTimeout = fmt.Errorf("script timeout")
+
+// scriptEnv runs the scripts of the current page with a small DOM, which
+// reads and changes the nodes of the page.
+type scriptEnv struct {
+	bow  *Browser
+	vm   *otto.Otto
+	page *url.URL
+
+	// nodes are the nodes handed to the scripts, by their handle.
+	nodes   []*html.Node
+	handles map[*html.Node]int
+
+	// current is the script element being run, or nil when a timer runs.
+	current *html.Node
+	written strings.Builder
+
+	// navigation loads the page a script navigated to.
+	navigation func() error
+}
+
+// runScripts runs the inline and same-origin scripts of the current page,
+// then the load event handlers and the timers. Returns the navigation
+// started by the scripts, such as a location change or a form submission,
+// or nil.
+func (bow *Browser) runScripts() func() error {
+	env := &scriptEnv{
+		bow:     bow,
+		vm:      otto.New(),
+		page:    bow.Url(),
+		handles: make(map[*html.Node]int),
+	}
+	env.vm.Interrupt = make(chan func(), 1)
+	if err := env.setup(); err != nil {
+		env.debug(err)
+		return nil
+	}
+
+	ran := make(map[*html.Node]bool)
+	for env.navigation == nil {
+		var next *html.Node
+		bow.Find("script").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			if ran[s.Get(0)] {
+				return true
+			}
+			next = s.Get(0)
+			return false
+		})
+		if next == nil {
+			break
+		}
+		ran[next] = true
+		src, ok := env.source(next)
+		if !ok {
+			continue
+		}
+		env.current = next
+		env.run(src)
+		env.flush()
+		env.current = nil
+	}
+
+	if env.navigation == nil {
+		env.run("_loaded()")
+		env.flush()
+	}
+	for i := 0; i < maxScriptTimers && env.navigation == nil; i++ {
+		if v, err := env.run("_nextTimer()").ToBoolean(); err != nil || !v {
+			break
+		}
+		env.flush()
+	}
+	return env.navigation
+}
+
+// setup defines the DOM of the page in the engine.
+func (env *scriptEnv) setup() error {
+	title := normalizeSpace(env.bow.Find("title").First().Text())
+	ref := ""
+	if env.bow.state.Request != nil {
+		ref = env.bow.state.Request.Referer()
+	}
+	u := env.page
+	origin := u.Scheme + "://" + u.Host
+	hash := ""
+	if u.Fragment != "" {
+		hash = "#" + u.Fragment
+	}
+	search := ""
+	if u.RawQuery != "" {
+		search = "?" + u.RawQuery
+	}
+	page, err := json.Marshal(map[string]string{
+		"href":      u.String(),
+		"protocol":  u.Scheme + ":",
+		"host":      u.Host,
+		"hostname":  u.Hostname(),
+		"port":      u.Port(),
+		"pathname":  u.EscapedPath(),
+		"search":    search,
+		"hash":      hash,
+		"origin":    origin,
+		"referrer":  ref,
+		"title":     title,
+		"userAgent": env.bow.userAgent,
+	})
+	if err != nil {
+		return err
+	}
+
+	surf, err := env.vm.Object("({})")
+	if err != nil {
+		return err
+	}
+	natives := map[string]func(otto.FunctionCall) otto.Value{
+		"byId":       env.byID,
+		"byName":     env.byName,
+		"find":       env.find,
+		"tag":        env.tag,
+		"parent":     env.parent,
+		"form":       env.form,
+		"attr":       env.attr,
+		"setAttr":    env.setAttr,
+		"removeAttr": env.removeAttr,
+		"value":      env.value,
+		"setValue":   env.setValue,
+		"text":       env.text,
+		"setText":    env.setText,
+		"html":       env.innerHTML,
+		"setHtml":    env.setInnerHTML,
+		"submit":     env.submit,
+		"click":      env.click,
+		"cookie":     env.cookie,
+		"setCookie":  env.setCookie,
+		"navigate":   env.navigate,
+		"write":      env.write,
+	}
+	for name, fn := range natives {
+		if err := surf.Set(name, fn); err != nil {
+			return err
+		}
+	}
+	if err := env.vm.Set("_surf", surf); err != nil {
+		return err
+	}
+	if err := env.vm.Set("atob", env.atob); err != nil {
+		return err
+	}
+	if err := env.vm.Set("btoa", env.btoa); err != nil {
+		return err
+	}
+	_, err = env.vm.Run("var _page = " + string(page) + ";\n" + scriptShim)
+	return err
+}
+
+// source returns the code of a script element. Returns false for scripts
+// which are not JavaScript, and for scripts of other origins.
+func (env *scriptEnv) source(n *html.Node) (string, bool) {
+	s := nodeSelection(n)
+	switch strings.ToLower(strings.TrimSpace(attrOr(s, "type", ""))) {
+	case "", "text/javascript", "application/javascript", "application/x-javascript",
+		"text/ecmascript", "application/ecmascript", "text/jscript":
+	default:
+		return "", false
+	}
+	src, ok := s.Attr("src")
+	if !ok {
+		return s.Text(), true
+	}
+	su, err := url.Parse(strings.TrimSpace(src))
+	if err != nil {
+		return "", false
+	}
+	su = env.bow.ResolveUrl(su)
+	if su.Scheme != env.page.Scheme || su.Host != env.page.Host {
+		return "", false
+	}
+	var buf bytes.Buffer
+	if _, err := env.bow.DownloadAsset(NewScriptAsset(su, "", ""), &buf); err != nil {
+		env.debug(err)
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// run runs the code, stopping it when it runs longer than ScriptTimeout.
+// Errors are ignored the way browsers ignore them, and are printed when the
+// SURF_DEBUG_SCRIPTS environment variable is set.
+func (env *scriptEnv) run(src string) (v otto.Value) {
+	v = otto.UndefinedValue()
+	timer := time.AfterFunc(ScriptTimeout, func() {
+		select {
+		case env.vm.Interrupt <- func() { panic(errScriptTimeout) }:
+		default:
+		}
+	})
+	defer func() {
+		timer.Stop()
+		select {
+		case <-env.vm.Interrupt:
+		default:
+		}
+		if r := recover(); r != nil {
+			env.debug(fmt.Errorf("%v", r))
+		}
+	}()
+	v, err := env.vm.Run(src)
+	if err != nil {
+		env.debug(err)
+	}
+	return v
+}
+
+// debug prints an error of a script.
+func (env *scriptEnv) debug(err error) {
+	if os.Getenv("SURF_DEBUG_SCRIPTS") != "" {
+		fmt.Fprintf(os.Stderr, "script error in %s: %s\n", env.page, err)
+	}
+}
+
+// flush inserts the markup written by document.write after the running
+// script, or at the end of the body when a timer wrote it.
+func (env *scriptEnv) flush() {
+	if env.written.Len() == 0 {
+		return
+	}
+	markup := env.written.String()
+	env.written.Reset()
+
+	parent, before := env.current, (*html.Node)(nil)
+	if parent != nil && parent.Parent != nil {
+		parent, before = parent.Parent, parent.NextSibling
+	} else if body := env.bow.Find("body"); body.Length() > 0 {
+		parent = body.Get(0)
+	} else {
+		return
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(markup), parent)
+	if err != nil {
+		env.debug(err)
+		return
+	}
+	for _, n := range nodes {
+		parent.InsertBefore(n, before)
+	}
+}
+
+// handle returns the handle of the node.
+func (env *scriptEnv) handle(n *html.Node) int {
+	if n == nil {
+		return -1
+	}
+	if h, ok := env.handles[n]; ok {
+		return h
+	}
+	env.nodes = append(env.nodes, n)
+	env.handles[n] = len(env.nodes) - 1
+	return len(env.nodes) - 1
+}
+
+// node returns the node of the handle in the first argument.
+func (env *scriptEnv) node(call otto.FunctionCall) *html.Node {
+	h, err := call.Argument(0).ToInteger()
+	if err != nil || h < 0 || int(h) >= len(env.nodes) {
+		panic(call.Otto.MakeTypeError("not an element"))
+	}
+	return env.nodes[h]
+}
+
+// toValue returns the Go value as a JavaScript value.
+func (env *scriptEnv) toValue(v interface{}) otto.Value {
+	val, err := env.vm.ToValue(v)
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+	return val
+}
+
+// array returns the handles of the nodes as a JavaScript array.
+func (env *scriptEnv) array(nodes []*html.Node) otto.Value {
+	arr, err := env.vm.Object("[]")
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+	for _, n := range nodes {
+		arr.Call("push", env.handle(n))
+	}
+	return arr.Value()
+}
+
+func (env *scriptEnv) byID(call otto.FunctionCall) otto.Value {
+	id := call.Argument(0).String()
+	var found *html.Node
+	env.bow.Find("[id]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if attrOr(s, "id", "") == id {
+			found = s.Get(0)
+			return false
+		}
+		return true
+	})
+	return env.toValue(env.handle(found))
+}
+
+func (env *scriptEnv) byName(call otto.FunctionCall) otto.Value {
+	name := call.Argument(0).String()
+	var nodes []*html.Node
+	env.bow.Find("[name]").Each(func(_ int, s *goquery.Selection) {
+		if attrOr(s, "name", "") == name {
+			nodes = append(nodes, s.Get(0))
+		}
+	})
+	return env.array(nodes)
+}
+
+func (env *scriptEnv) find(call otto.FunctionCall) (v otto.Value) {
+	// Selectors which cannot be parsed match nothing.
+	defer func() {
+		if r := recover(); r != nil {
+			v = env.array(nil)
+		}
+	}()
+	sel := env.bow.Dom()
+	if h, _ := call.Argument(0).ToInteger(); h >= 0 {
+		sel = nodeSelection(env.node(call))
+	}
+	found := sel.Find(call.Argument(1).String())
+	if all, _ := call.Argument(2).ToBoolean(); !all {
+		found = found.First()
+	}
+	return env.array(found.Nodes)
+}
+
+func (env *scriptEnv) tag(call otto.FunctionCall) otto.Value {
+	return env.toValue(strings.ToUpper(env.node(call).Data))
+}
+
+func (env *scriptEnv) parent(call otto.FunctionCall) otto.Value {
+	p := env.node(call).Parent
+	if p != nil && p.Type != html.ElementNode {
+		p = nil
+	}
+	return env.toValue(env.handle(p))
+}
+
+func (env *scriptEnv) form(call otto.FunctionCall) otto.Value {
+	owner := env.bow.ownerForm(nodeSelection(env.node(call)))
+	if owner == nil {
+		return env.toValue(-1)
+	}
+	return env.toValue(env.handle(owner.Get(0)))
+}
+
+func (env *scriptEnv) attr(call otto.FunctionCall) otto.Value {
+	if v, ok := nodeSelection(env.node(call)).Attr(call.Argument(1).String()); ok {
+		return env.toValue(v)
+	}
+	return otto.NullValue()
+}
+
+func (env *scriptEnv) setAttr(call otto.FunctionCall) otto.Value {
+	setNodeAttr(env.node(call), call.Argument(1).String(), call.Argument(2).String())
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) removeAttr(call otto.FunctionCall) otto.Value {
+	removeNodeAttr(env.node(call), call.Argument(1).String())
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) value(call otto.FunctionCall) otto.Value {
+	n := env.node(call)
+	s := nodeSelection(n)
+	switch n.Data {
+	case "textarea":
+		return env.toValue(s.Text())
+	case "select":
+		for _, o := range selectOptions(s, !hasNodeAttr(n, "multiple")) {
+			if o.selected {
+				return env.toValue(o.value)
+			}
+		}
+		return env.toValue("")
+	}
+	return env.toValue(attrOr(s, "value", ""))
+}
+
+func (env *scriptEnv) setValue(call otto.FunctionCall) otto.Value {
+	n := env.node(call)
+	value := call.Argument(1).String()
+	switch n.Data {
+	case "textarea":
+		setNodeText(n, value)
+	case "select":
+		nodeSelection(n).Find("option").Each(func(_ int, o *goquery.Selection) {
+			if attrOr(o, "value", normalizeSpace(o.Text())) == value {
+				setNodeAttr(o.Get(0), "selected", "")
+			} else {
+				removeNodeAttr(o.Get(0), "selected")
+			}
+		})
+	default:
+		setNodeAttr(n, "value", value)
+	}
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) text(call otto.FunctionCall) otto.Value {
+	return env.toValue(nodeSelection(env.node(call)).Text())
+}
+
+func (env *scriptEnv) setText(call otto.FunctionCall) otto.Value {
+	setNodeText(env.node(call), call.Argument(1).String())
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) innerHTML(call otto.FunctionCall) otto.Value {
+	markup, _ := nodeSelection(env.node(call)).Html()
+	return env.toValue(markup)
+}
+
+func (env *scriptEnv) setInnerHTML(call otto.FunctionCall) otto.Value {
+	n := env.node(call)
+	nodes, err := html.ParseFragment(strings.NewReader(call.Argument(1).String()), n)
+	if err != nil {
+		panic(call.Otto.MakeSyntaxError(err.Error()))
+	}
+	removeChildren(n)
+	for _, c := range nodes {
+		n.AppendChild(c)
+	}
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) submit(call otto.FunctionCall) otto.Value {
+	s := nodeSelection(env.node(call))
+	if s.Is("form") {
+		env.navigation = func() error {
+			return NewForm(env.bow, s).send(nil)
+		}
+	}
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) click(call otto.FunctionCall) otto.Value {
+	n := env.node(call)
+	s := nodeSelection(n)
+	typ := strings.ToLower(attrOr(s, "type", ""))
+	switch {
+	case s.Is("a[href],area[href]"), isSubmitButton(s):
+		env.navigation = func() error {
+			return env.bow.clickElement(s, "")
+		}
+	case s.Is("input") && (typ == "checkbox" || typ == "radio"):
+		if hasNodeAttr(n, "checked") && typ == "checkbox" {
+			removeNodeAttr(n, "checked")
+		} else {
+			setNodeAttr(n, "checked", "")
+		}
+	}
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) cookie(call otto.FunctionCall) otto.Value {
+	if env.bow.client == nil || env.bow.client.Jar == nil {
+		return env.toValue("")
+	}
+	var pairs []string
+	for _, c := range env.bow.client.Jar.Cookies(env.page) {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	return env.toValue(strings.Join(pairs, "; "))
+}
+
+func (env *scriptEnv) setCookie(call otto.FunctionCall) otto.Value {
+	if env.bow.client == nil || env.bow.client.Jar == nil {
+		return otto.UndefinedValue()
+	}
+	resp := &http.Response{Header: http.Header{"Set-Cookie": {call.Argument(0).String()}}}
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		env.bow.client.Jar.SetCookies(env.page, cookies)
+	}
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) navigate(call otto.FunctionCall) otto.Value {
+	target := strings.TrimSpace(call.Argument(0).String())
+	if strings.HasPrefix(strings.ToLower(target), "javascript:") {
+		return otto.UndefinedValue()
+	}
+	tu, err := url.Parse(target)
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+	u := env.bow.ResolveUrl(tu)
+	// Changing the fragment does not load a page.
+	current := *env.page
+	current.Fragment = u.Fragment
+	if u.Fragment != "" && current.String() == u.String() {
+		return otto.UndefinedValue()
+	}
+	ref := env.page
+	env.navigation = func() error {
+		return env.bow.httpGET(u, ref)
+	}
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) write(call otto.FunctionCall) otto.Value {
+	env.written.WriteString(call.Argument(0).String())
+	return otto.UndefinedValue()
+}
+
+func (env *scriptEnv) atob(call otto.FunctionCall) otto.Value {
+	s := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			return -1
+		}
+		return r
+	}, call.Argument(0).String())
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		b, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	if err != nil {
+		panic(call.Otto.MakeCustomError("InvalidCharacterError", "The string to be decoded is not correctly encoded."))
+	}
+	// Each byte is a character of the result, the way browsers decode it.
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return env.toValue(string(runes))
+}
+
+func (env *scriptEnv) btoa(call otto.FunctionCall) otto.Value {
+	s := call.Argument(0).String()
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			panic(call.Otto.MakeCustomError("InvalidCharacterError", "The string to be encoded contains characters outside of the Latin1 range."))
+		}
+		b = append(b, byte(r))
+	}
+	return env.toValue(base64.StdEncoding.EncodeToString(b))
+}
+
+// nodeSelection returns a selection of the node.
+func nodeSelection(n *html.Node) *goquery.Selection {
+	return &goquery.Selection{Nodes: []*html.Node{n}}
+}
+
+// hasNodeAttr returns true when the node has the attribute.
+func hasNodeAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// setNodeAttr sets the attribute of the node.
+func setNodeAttr(n *html.Node, key, value string) {
+	key = strings.ToLower(key)
+	for i, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
+
+// removeNodeAttr removes the attribute of the node.
+func removeNodeAttr(n *html.Node, key string) {
+	key = strings.ToLower(key)
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Namespace != "" || a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
+
+// setNodeText replaces the children of the node with the text.
+func setNodeText(n *html.Node, text string) {
+	removeChildren(n)
+	n.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+}
+
+// removeChildren removes the children of the node.
+func removeChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+	}
+}
+
+// scriptShim is the window, document and element objects of the scripts,
+// implemented with the _surf functions.
+const scriptShim = `
+var window = this, self = this, top = this, parent = this, frames = this;
+var console = {log: function() {}, info: function() {}, warn: function() {}, error: function() {}, debug: function() {}};
+var navigator = {userAgent: _page.userAgent, language: "en-US", languages: ["en-US", "en"], cookieEnabled: true, onLine: true};
+
+var _listeners = {DOMContentLoaded: [], load: []};
+function addEventListener(type, fn) {
+	if (_listeners[type] && typeof fn === "function") {
+		_listeners[type].push(fn);
+	}
+}
+function removeEventListener() {}
+
+var _timers = [], _timerId = 0, _clock = 0;
+function setTimeout(fn, delay) {
+	var args = Array.prototype.slice.call(arguments, 2);
+	_timerId++;
+	_timers.push({id: _timerId, fn: fn, at: _clock + (Number(delay) || 0), args: args});
+	return _timerId;
+}
+function clearTimeout(id) {
+	_timers = _timers.filter(function(t) { return t.id !== id; });
+}
+var setInterval = setTimeout, clearInterval = clearTimeout;
+function _nextTimer() {
+	if (_timers.length === 0) {
+		return false;
+	}
+	var next = 0;
+	for (var i = 1; i < _timers.length; i++) {
+		if (_timers[i].at < _timers[next].at) {
+			next = i;
+		}
+	}
+	var t = _timers.splice(next, 1)[0];
+	_clock = t.at;
+	if (typeof t.fn === "function") {
+		t.fn.apply(window, t.args);
+	} else {
+		(0, eval)(String(t.fn));
+	}
+	return true;
+}
+function _loaded() {
+	document.readyState = "complete";
+	var run = function(fn, target, type) {
+		try { fn.call(target, {type: type, target: target}); } catch (e) {}
+	};
+	_listeners.DOMContentLoaded.forEach(function(fn) { run(fn, document, "DOMContentLoaded"); });
+	_listeners.load.forEach(function(fn) { run(fn, window, "load"); });
+	if (typeof window.onload === "function") {
+		run(window.onload, window, "load");
+	}
+	var body = document.body;
+	if (body && body.hasAttribute("onload")) {
+		run(new Function("event", body.getAttribute("onload")), body, "load");
+	}
+}
+
+function Location() {}
+["protocol", "host", "hostname", "port", "pathname", "search", "hash", "origin"].forEach(function(k) {
+	Object.defineProperty(Location.prototype, k, {get: function() { return _page[k]; }});
+});
+Object.defineProperty(Location.prototype, "href", {
+	get: function() { return _page.href; },
+	set: function(v) { _surf.navigate(String(v)); }
+});
+Location.prototype.assign = function(u) { _surf.navigate(String(u)); };
+Location.prototype.replace = Location.prototype.assign;
+Location.prototype.reload = function() { _surf.navigate(_page.href); };
+Location.prototype.toString = function() { return _page.href; };
+var _location = new Location();
+Object.defineProperty(window, "location", {
+	get: function() { return _location; },
+	set: function(v) { _surf.navigate(String(v)); }
+});
+
+var _elements = {};
+function _element(h) {
+	if (h < 0) {
+		return null;
+	}
+	if (!_elements[h]) {
+		_elements[h] = new Element(h);
+	}
+	return _elements[h];
+}
+function _list(hs) {
+	var out = [];
+	for (var i = 0; i < hs.length; i++) {
+		out.push(_element(hs[i]));
+	}
+	return out;
+}
+
+function Element(h) {
+	this._h = h;
+	this.style = {};
+	if (_surf.tag(h) === "FORM") {
+		var form = this;
+		this.elements = _list(_surf.find(h, "input,select,textarea,button", true));
+		this.elements.forEach(function(e) {
+			var name = e.name || e.id;
+			if (name && !(name in form)) {
+				form[name] = e;
+			}
+		});
+	}
+}
+function _reflect(prop, attr) {
+	Object.defineProperty(Element.prototype, prop, {
+		get: function() { var v = _surf.attr(this._h, attr); return v === null ? "" : v; },
+		set: function(v) { _surf.setAttr(this._h, attr, String(v)); }
+	});
+}
+["id", "name", "type", "href", "src", "action", "method", "title", "alt", "rel", "target"].forEach(function(k) {
+	_reflect(k, k);
+});
+_reflect("className", "class");
+function _flag(prop) {
+	Object.defineProperty(Element.prototype, prop, {
+		get: function() { return _surf.attr(this._h, prop) !== null; },
+		set: function(v) { if (v) { _surf.setAttr(this._h, prop, ""); } else { _surf.removeAttr(this._h, prop); } }
+	});
+}
+_flag("checked");
+_flag("disabled");
+_flag("selected");
+Object.defineProperty(Element.prototype, "tagName", {get: function() { return _surf.tag(this._h); }});
+Object.defineProperty(Element.prototype, "nodeName", {get: function() { return _surf.tag(this._h); }});
+Object.defineProperty(Element.prototype, "parentNode", {get: function() { return _element(_surf.parent(this._h)); }});
+Object.defineProperty(Element.prototype, "form", {get: function() { return _element(_surf.form(this._h)); }});
+Object.defineProperty(Element.prototype, "value", {
+	get: function() { return _surf.value(this._h); },
+	set: function(v) { _surf.setValue(this._h, String(v)); }
+});
+Object.defineProperty(Element.prototype, "innerHTML", {
+	get: function() { return _surf.html(this._h); },
+	set: function(v) { _surf.setHtml(this._h, String(v)); }
+});
+["textContent", "innerText"].forEach(function(k) {
+	Object.defineProperty(Element.prototype, k, {
+		get: function() { return _surf.text(this._h); },
+		set: function(v) { _surf.setText(this._h, String(v)); }
+	});
+});
+Element.prototype.nodeType = 1;
+Element.prototype.getAttribute = function(name) { return _surf.attr(this._h, String(name)); };
+Element.prototype.setAttribute = function(name, v) { _surf.setAttr(this._h, String(name), String(v)); };
+Element.prototype.removeAttribute = function(name) { _surf.removeAttr(this._h, String(name)); };
+Element.prototype.hasAttribute = function(name) { return _surf.attr(this._h, String(name)) !== null; };
+Element.prototype.querySelector = function(s) {
+	var hs = _surf.find(this._h, String(s), false);
+	return hs.length ? _element(hs[0]) : null;
+};
+Element.prototype.querySelectorAll = function(s) { return _list(_surf.find(this._h, String(s), true)); };
+Element.prototype.getElementsByTagName = function(name) { return this.querySelectorAll(String(name)); };
+Element.prototype.submit = function() { _surf.submit(this._h); };
+Element.prototype.click = function() { _surf.click(this._h); };
+Element.prototype.addEventListener = function() {};
+Element.prototype.removeEventListener = function() {};
+Element.prototype.focus = function() {};
+Element.prototype.blur = function() {};
+
+var document = {
+	nodeType: 9,
+	readyState: "loading",
+	referrer: _page.referrer,
+	title: _page.title,
+	getElementById: function(id) { return _element(_surf.byId(String(id))); },
+	getElementsByName: function(name) { return _list(_surf.byName(String(name))); },
+	getElementsByTagName: function(name) { return this.querySelectorAll(String(name)); },
+	querySelector: function(s) {
+		var hs = _surf.find(-1, String(s), false);
+		return hs.length ? _element(hs[0]) : null;
+	},
+	querySelectorAll: function(s) { return _list(_surf.find(-1, String(s), true)); },
+	write: function() { _surf.write(Array.prototype.join.call(arguments, "")); },
+	writeln: function() { _surf.write(Array.prototype.join.call(arguments, "") + "\n"); },
+	addEventListener: addEventListener,
+	removeEventListener: removeEventListener
+};
+Object.defineProperty(document, "cookie", {
+	get: function() { return _surf.cookie(); },
+	set: function(v) { _surf.setCookie(String(v)); }
+});
+Object.defineProperty(document, "location", {
+	get: function() { return _location; },
+	set: function(v) { _surf.navigate(String(v)); }
+});
+Object.defineProperty(document, "forms", {get: function() {
+	var forms = this.querySelectorAll("form");
+	forms.forEach(function(f) {
+		if (f.name && !(f.name in forms)) {
+			forms[f.name] = f;
+		}
+	});
+	return forms;
+}});
+Object.defineProperty(document, "body", {get: function() { return this.querySelector("body"); }});
+Object.defineProperty(document, "head", {get: function() { return this.querySelector("head"); }});
+Object.defineProperty(document, "documentElement", {get: function() { return this.querySelector("html"); }});
+`
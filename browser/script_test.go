@@ -0,0 +1,152 @@
+package browser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/headzoo/ut"
+)
+
+func TestRunScripts(t *testing.T) {
+	ut.Run(t)
+	loops := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.js":
+			w.Header().Set("Content-Type", "application/javascript")
+			fmt.Fprint(w, `window.location = "/done?" + document.getElementById("x").textContent;`)
+		case "/loop":
+			loops++
+			fmt.Fprint(w, `<html><body><script>location.reload()</script></body></html>`)
+		case "/cookie", "/write", "/timer", "/onload", "/external", "/timeout":
+			fmt.Fprint(w, htmlScripts[r.URL.Path])
+		default:
+			b, _ := ioutil.ReadAll(r.Body)
+			cookie, _ := r.Cookie("js")
+			value := ""
+			if cookie != nil {
+				value = cookie.Value
+			}
+			fmt.Fprintf(w, `<html><title>%s %s</title><body><p id="cookie">%s</p><p id="query">%s</p><p id="data">%s</p></body></html>`,
+				r.Method, r.URL.Path, value, r.URL.RawQuery, b)
+		}
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/cookie"))
+	ut.AssertEquals("Cookie", bow.Title())
+
+	bow.SetAttribute(RunScripts, true)
+	ut.AssertNil(bow.Open(ts.URL + "/cookie"))
+	ut.AssertEquals("GET /next", bow.Title())
+	ut.AssertEquals("a b", bow.Find("#cookie").Text())
+	ut.AssertEquals(ts.URL+"/cookie", bow.state.Request.Referer())
+
+	ut.AssertNil(bow.Open(ts.URL + "/write"))
+	ut.AssertEquals("Write", bow.Title())
+	ut.AssertEquals("3", bow.Find("#out #w").Text())
+	ut.AssertEquals("from write", bow.Find("#out #nested").Text())
+	ut.AssertEquals("changed", bow.Find("#target").Text())
+
+	ut.AssertNil(bow.Open(ts.URL + "/timer"))
+	ut.AssertEquals("POST /submit", bow.Title())
+	ut.AssertEquals("token=eA%3D%3D&user=joe", bow.Find("#data").Text())
+
+	ut.AssertNil(bow.Open(ts.URL + "/onload"))
+	ut.AssertEquals("GET /clicked", bow.Title())
+
+	ut.AssertNil(bow.Open(ts.URL + "/external"))
+	ut.AssertEquals("GET /done", bow.Title())
+	ut.AssertEquals("42", bow.Find("#query").Text())
+
+	timeout := ScriptTimeout
+	ScriptTimeout = 100 * time.Millisecond
+	defer func() { ScriptTimeout = timeout }()
+	ut.AssertNil(bow.Open(ts.URL + "/timeout"))
+	ut.AssertEquals("GET /after", bow.Title())
+
+	ut.AssertNil(bow.Open(ts.URL + "/loop"))
+	ut.AssertEquals(DefaultMaxRefreshes+1, loops)
+}
+
+var htmlScripts = map[string]string{
+	"/cookie": `<!doctype html>
+<html>
+	<head><title>Cookie</title></head>
+	<body>
+		<script>
+			document.cookie = "js=a b; path=/";
+			if (document.cookie.indexOf("js=a b") >= 0) {
+				location.href = "/next";
+			}
+		</script>
+	</body>
+</html>
+`,
+	"/write": `<!doctype html>
+<html>
+	<head><title>Write</title></head>
+	<body>
+		<div id="out">
+			<script>
+				document.write("<p id='w'>" + (1 + 2) + "</p>");
+				document.write("<script>document.write('<b id=nested>from write</b>')<\/script>");
+			</script>
+		</div>
+		<p id="target">original</p>
+		<script type="text/template">location.href = "/template"</script>
+		<script>document.querySelector("#target").textContent = "changed";</script>
+		<script>location.hash = "top"; location.href = "#top";</script>
+	</body>
+</html>
+`,
+	"/timer": `<!doctype html>
+<html>
+	<body>
+		<form id="login" method="post" action="/submit">
+			<input name="token">
+			<input name="user">
+		</form>
+		<script>
+			setTimeout(function() {
+				var form = document.getElementById("login");
+				form.token.value = btoa("x");
+				document.forms[0].submit();
+			}, 1000);
+			var early = setTimeout(function() { location.href = "/early"; }, 10);
+			clearTimeout(early);
+			document.getElementsByName("user")[0].value = "joe";
+		</script>
+	</body>
+</html>
+`,
+	"/onload": `<!doctype html>
+<html>
+	<body onload="document.querySelector('a.go').click()">
+		<a class="go" href="/clicked">Go</a>
+	</body>
+</html>
+`,
+	"/external": `<!doctype html>
+<html>
+	<body>
+		<p id="x">42</p>
+		<script src="//other.invalid/app.js"></script>
+		<script src="/app.js"></script>
+	</body>
+</html>
+`,
+	"/timeout": `<!doctype html>
+<html>
+	<body>
+		<script>while (true) {}</script>
+		<script>window.addEventListener("load", function() { location.assign("/after"); });</script>
+	</body>
+</html>
+`,
+}
@@ -0,0 +1,265 @@
+package browser
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Diggernaut/goquery"
+	"github.com/dataxpe/surf/errors"
+	"github.com/dataxpe/surf/jar"
+)
+
+// SessionVersion is the version of the session format written by SaveSession.
+const SessionVersion = 1
+
+// Session is the serialized form of a browser session.
+type Session struct {
+	// Version is the version of the session format.
+	Version int `json:"version"`
+
+	// UserAgent is the User-Agent header value sent with requests.
+	UserAgent string `json:"user_agent"`
+
+	// Headers are the additional headers sent with each request.
+	Headers http.Header `json:"headers,omitempty"`
+
+	// Attributes are the browser attributes.
+	Attributes AttributeMap `json:"attributes,omitempty"`
+
+	// Bookmarks are the saved bookmarks.
+	Bookmarks jar.BookmarksMap `json:"bookmarks,omitempty"`
+
+	// URL is the URL of the current page.
+	URL string `json:"url,omitempty"`
+
+	// Cookies are the cookies in the cookie jar.
+	Cookies []*SessionCookie `json:"cookies,omitempty"`
+}
+
+// SessionCookie is a cookie along with the URL of the response that set it.
+type SessionCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// SaveSession writes the browser session to w as JSON. The session holds
+// the cookies, the request headers, the user agent, the attributes, the
+// bookmarks and the current URL.
+func (bow *Browser) SaveSession(w io.Writer) error {
+	s := &Session{
+		Version:    SessionVersion,
+		UserAgent:  bow.userAgent,
+		Headers:    bow.headers,
+		Attributes: bow.attributes,
+	}
+	if bow.bookmarks != nil {
+		s.Bookmarks = bow.bookmarks.All()
+	}
+	if u := bow.Url(); u != nil {
+		s.URL = u.String()
+	}
+	if rec := bow.cookieRecorder(); rec != nil {
+		s.Cookies = rec.cookies()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// LoadSession restores a browser session written by SaveSession.
+//
+// The cookies are added to the current cookie jar, and the bookmarks to the
+// current bookmarks jar. The current URL is restored without requesting the
+// page, use Reload to load it.
+func (bow *Browser) LoadSession(r io.Reader) error {
+	s := &Session{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return err
+	}
+	if s.Version < 1 || s.Version > SessionVersion {
+		return errors.New("Unsupported session version %d.", s.Version)
+	}
+
+	bow.userAgent = s.UserAgent
+	bow.headers = s.Headers
+	if bow.headers == nil {
+		bow.headers = jar.NewMemoryHeaders()
+	}
+	// Attributes missing from older sessions keep their values.
+	if bow.attributes == nil {
+		bow.attributes = AttributeMap{}
+	}
+	for attr, val := range s.Attributes {
+		bow.attributes[attr] = val
+	}
+	if bow.bookmarks != nil {
+		for name, u := range s.Bookmarks {
+			bow.bookmarks.Remove(name)
+			if err := bow.bookmarks.Save(name, u); err != nil {
+				return err
+			}
+		}
+	}
+
+	if bow.client == nil {
+		bow.client = bow.buildClient()
+	}
+	if rec := bow.cookieRecorder(); rec != nil {
+		// The cookies were accepted by the cookie policy when they were
+		// saved, so they go straight to the jar.
+		for _, c := range s.Cookies {
+			u, err := url.Parse(c.URL)
+			if err != nil {
+				return err
+			}
+			rec.SetCookies(u, []*http.Cookie{c.Cookie})
+		}
+	}
+
+	if s.URL != "" {
+		req, err := bow.buildRequest("GET", s.URL, nil, nil)
+		if err != nil {
+			return err
+		}
+		dom, err := goquery.NewDocumentFromReader(strings.NewReader(""))
+		if err != nil {
+			return err
+		}
+		bow.body = nil
+		bow.state = jar.NewHistoryState(req, nil, dom)
+	}
+	return nil
+}
+
+// cookieRecorder returns the recorder wrapping the cookie jar, or nil when
+// the browser has no cookie jar.
+func (bow *Browser) cookieRecorder() *cookieRecorder {
+	if bow.client == nil {
+		return nil
+	}
+	cj := bow.client.Jar
+	if pj, ok := cj.(*jar.PolicyJar); ok {
+		cj = pj.CookieJar
+	}
+	rec, _ := cj.(*cookieRecorder)
+	return rec
+}
+
+// applyCookiePolicy wraps the recorder with the cookie policy when one is
+// set.
+func (bow *Browser) applyCookiePolicy(rec *cookieRecorder) http.CookieJar {
+	if bow.cookiePolicy == nil {
+		return rec
+	}
+	return jar.NewPolicyJar(rec, bow.cookiePolicy)
+}
+
+// cookieRecorder is a cookie jar that remembers the cookies set through it,
+// because http.CookieJar has no way to list its cookies.
+type cookieRecorder struct {
+	http.CookieJar
+	mu      sync.Mutex
+	records map[string]*SessionCookie
+}
+
+// newCookieRecorder creates and returns a *cookieRecorder wrapping cj.
+func newCookieRecorder(cj http.CookieJar) *cookieRecorder {
+	if rec, ok := cj.(*cookieRecorder); ok {
+		return rec
+	}
+	return &cookieRecorder{
+		CookieJar: cj,
+		records:   make(map[string]*SessionCookie),
+	}
+}
+
+// SetCookies passes the cookies on to the wrapped jar, and records the
+// cookies it stored. Cookies refused by the jar are not recorded.
+func (rec *cookieRecorder) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	rec.CookieJar.SetCookies(u, cookies)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		domain := c.Domain
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		key := strings.ToLower(strings.TrimPrefix(domain, ".")) + ";" + c.Path + ";" + c.Name
+		if c.MaxAge < 0 || !c.Expires.IsZero() && c.Expires.Before(now) {
+			delete(rec.records, key)
+			continue
+		}
+		if !jar.HasCookie(rec.CookieJar, u, c) {
+			continue
+		}
+		// Max-Age is relative to when the cookie was set, so it is kept as
+		// an expiry time instead.
+		cc := *c
+		if cc.MaxAge > 0 {
+			cc.Expires = now.Add(time.Duration(cc.MaxAge) * time.Second)
+			cc.MaxAge = 0
+		}
+		rec.records[key] = &SessionCookie{URL: u.String(), Cookie: &cc}
+	}
+}
+
+// cookies returns the recorded cookies which have not expired. The cookies
+// of a jar.CookieJar are listed from the jar itself, which also holds the
+// cookies added without going through the recorder.
+func (rec *cookieRecorder) cookies() []*SessionCookie {
+	if cj, ok := rec.CookieJar.(*jar.CookieJar); ok {
+		all := cj.All()
+		cookies := make([]*SessionCookie, len(all))
+		for i, c := range all {
+			cookies[i] = newSessionCookie(c)
+		}
+		return cookies
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	now := time.Now()
+	keys := make([]string, 0, len(rec.records))
+	for key, c := range rec.records {
+		if !c.Cookie.Expires.IsZero() && c.Cookie.Expires.Before(now) {
+			delete(rec.records, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cookies := make([]*SessionCookie, len(keys))
+	for i, key := range keys {
+		cookies[i] = rec.records[key]
+	}
+	return cookies
+}
+
+// newSessionCookie converts a cookie listed from a jar.CookieJar.
+func newSessionCookie(c *jar.Cookie) *SessionCookie {
+	u := &url.URL{Scheme: "http", Host: c.Domain, Path: c.Path}
+	if c.Secure {
+		u.Scheme = "https"
+	}
+	hc := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+	if !c.HostOnly {
+		hc.Domain = c.Domain
+	}
+	return &SessionCookie{URL: u.String(), Cookie: hc}
+}
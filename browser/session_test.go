@@ -0,0 +1,126 @@
+package browser
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dataxpe/surf/agent"
+	"github.com/dataxpe/surf/jar"
+	"github.com/headzoo/ut"
+)
+
+func TestSession(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "pref", Value: "1", Path: "/", MaxAge: 3600})
+			http.SetCookie(w, &http.Cookie{Name: "gone", Value: "x", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "gone", Path: "/", MaxAge: -1})
+			http.SetCookie(w, &http.Cookie{Name: "foreign", Value: "1", Domain: "example.org"})
+			fmt.Fprint(w, "<html><title>Logged in</title></html>")
+		default:
+			c, err := r.Cookie("session")
+			if err != nil {
+				fmt.Fprint(w, "<html><title>Anonymous</title></html>")
+				return
+			}
+			fmt.Fprintf(w, "<html><title>%s %s %s</title></html>",
+				c.Value, r.UserAgent(), r.Header.Get("X-Token"))
+		}
+	}))
+	defer ts.Close()
+
+	bow := newDefaultTestBrowser()
+	bow.SetUserAgent("Worker/1.0")
+	bow.AddRequestHeader("X-Token", "t0k3n")
+	err := bow.Open(ts.URL + "/login")
+	ut.AssertNil(err)
+	err = bow.Bookmark("home")
+	ut.AssertNil(err)
+	bow.SetAttribute(SendReferer, false)
+
+	buff := &bytes.Buffer{}
+	err = bow.SaveSession(buff)
+	ut.AssertNil(err)
+	// Cookies refused by the jar are not saved.
+	ut.AssertFalse(strings.Contains(buff.String(), "foreign"))
+
+	other := newDefaultTestBrowser()
+	err = other.LoadSession(bytes.NewReader(buff.Bytes()))
+	ut.AssertNil(err)
+	ut.AssertEquals("Worker/1.0", other.GetUserAgent())
+	ut.AssertEquals("t0k3n", other.GetRequestHeader("X-Token"))
+	ut.AssertEquals(ts.URL+"/login", other.Url().String())
+	ut.AssertFalse(other.attributes[SendReferer])
+
+	// Attributes missing from a session keep their values.
+	delete(bow.attributes, RunScripts)
+	buff.Reset()
+	ut.AssertNil(bow.SaveSession(buff))
+	older := newDefaultTestBrowser()
+	older.SetAttribute(RunScripts, true)
+	ut.AssertNil(older.LoadSession(bytes.NewReader(buff.Bytes())))
+	ut.AssertTrue(older.attributes[RunScripts])
+	ut.AssertFalse(older.attributes[SendReferer])
+	u, err := other.bookmarks.Read("home")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/login", u)
+
+	names := map[string]bool{}
+	for _, c := range other.SiteCookies() {
+		names[c.Name] = true
+	}
+	ut.AssertTrue(names["session"])
+	ut.AssertTrue(names["pref"])
+	ut.AssertFalse(names["gone"])
+
+	err = other.Open(ts.URL + "/account")
+	ut.AssertNil(err)
+	ut.AssertEquals("abc Worker/1.0 t0k3n", other.Title())
+
+	err = other.LoadSession(bytes.NewBufferString(`{"version": 99}`))
+	ut.AssertNotNil(err)
+}
+
+func TestSessionNewBrowser(t *testing.T) {
+	ut.Run(t)
+	bow := &Browser{}
+	bow.SetUserAgent(agent.Create())
+	bow.SetState(&jar.State{})
+	bow.SetCookieJar(jar.NewMemoryCookies())
+	bow.SetHeadersJar(jar.NewMemoryHeaders())
+
+	buff := &bytes.Buffer{}
+	err := bow.SaveSession(buff)
+	ut.AssertNil(err)
+	err = bow.LoadSession(buff)
+	ut.AssertNil(err)
+}
+
+func TestSessionCookieJar(t *testing.T) {
+	ut.Run(t)
+	cj := jar.NewCookieJar()
+	cj.Add(&jar.Cookie{Name: "imported", Value: "1", Domain: "example.com", Path: "/"})
+	bow := newDefaultTestBrowser()
+	bow.SetCookieJar(cj)
+
+	buff := &bytes.Buffer{}
+	err := bow.SaveSession(buff)
+	ut.AssertNil(err)
+
+	other := newDefaultTestBrowser()
+	other.SetCookieJar(jar.NewCookieJar())
+	err = other.LoadSession(buff)
+	ut.AssertNil(err)
+	u, _ := url.Parse("http://www.example.com/")
+	cookies := other.client.Jar.Cookies(u)
+	ut.AssertEquals(1, len(cookies))
+	ut.AssertEquals("imported", cookies[0].Name)
+}
@@ -0,0 +1,684 @@
+package browser
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPseudoHeaderOrder is the order of the HTTP/2 pseudo headers when
+// the header order does not list them.
+var defaultPseudoHeaderOrder = []string{":authority", ":method", ":path", ":scheme"}
+
+// headerOrderKey is the context key of the header order of a request.
+type headerOrderKey struct{}
+
+// WithHeaderOrder returns a copy of ctx carrying the order of the request
+// headers written by an OrderedTransport.
+//
+// The headers are written in the order of the list, spelled the way they are
+// listed. Headers which are not listed are written after the listed headers,
+// sorted by name. Entries starting with a colon are the HTTP/2 pseudo headers
+// ":method", ":authority", ":scheme" and ":path".
+func WithHeaderOrder(ctx context.Context, order []string) context.Context {
+	return context.WithValue(ctx, headerOrderKey{}, order)
+}
+
+// headerOrder returns the header order carried by ctx.
+func headerOrder(ctx context.Context) []string {
+	order, _ := ctx.Value(headerOrderKey{}).([]string)
+	return order
+}
+
+// OrderedTransport is an http.RoundTripper which writes the request headers
+// in the order given with WithHeaderOrder, instead of the sorted order of
+// http.Transport. Header names which are not canonical, e.g. "sec-ch-ua",
+// are written as they are.
+//
+// HTTP/2 is used when the server negotiates it, and HTTP/1.1 otherwise. One
+// request at a time is sent over each HTTP/2 connection. Response bodies are
+// not decompressed.
+type OrderedTransport struct {
+	// Proxy returns the proxy used for a request, the way it does for
+	// http.Transport. Only http proxies are supported.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// DialContext dials the connections. A net.Dialer is used when nil.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// TLSClientConfig is the configuration of TLS connections.
+	TLSClientConfig *tls.Config
+
+	// DisableHTTP2 only speaks HTTP/1.1.
+	DisableHTTP2 bool
+
+	mu    sync.Mutex
+	idle  map[string][]*h1Conn
+	conns map[string]*h2Conn
+}
+
+// NewOrderedTransport creates and returns a new *OrderedTransport type using
+// the proxy, dialer and TLS configuration of t, which may be nil.
+func NewOrderedTransport(t *http.Transport) *OrderedTransport {
+	ot := &OrderedTransport{}
+	if t != nil {
+		ot.Proxy = t.Proxy
+		ot.DialContext = t.DialContext
+		ot.TLSClientConfig = t.TLSClientConfig
+		ot.DisableHTTP2 = t.TLSNextProto != nil && len(t.TLSNextProto) == 0
+	}
+	return ot
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *OrderedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is closed once it has been sent, or the request failed.
+	defer func() { closeBody(req) }()
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported protocol scheme %q", req.URL.Scheme)
+	}
+	var proxy *url.URL
+	var err error
+	if t.Proxy != nil {
+		if proxy, err = t.Proxy(req); err != nil {
+			return nil, err
+		}
+	}
+	addr := canonicalAddr(req.URL)
+	key := req.URL.Scheme + "|" + addr
+	if proxy != nil {
+		key = proxy.String() + "|" + key
+	}
+	order := headerOrder(req.Context())
+
+	// A reused connection may have been closed by the server in the
+	// meantime, in which case the request is sent again on a new one when
+	// that is safe.
+	var resp *http.Response
+	reused := false
+	if c := t.getH2(key); c != nil {
+		resp, err = c.roundTrip(req, order)
+		reused = true
+	} else if c := t.getIdle(key); c != nil {
+		resp, err = t.roundTrip1(c, key, req, order, proxy)
+		reused = true
+	}
+	if reused {
+		if err == nil || req.Context().Err() != nil {
+			return resp, err
+		}
+		if req, err = rewindRequest(req, err); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, proto, err := t.dial(req, proxy, addr)
+	if err != nil {
+		return nil, err
+	}
+	if proto == "h2" {
+		c, err := newH2Conn(t, key, conn)
+		if err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		if t.conns == nil {
+			t.conns = make(map[string]*h2Conn)
+		}
+		c.busy = true
+		if old := t.conns[key]; old == nil || old.broken {
+			t.conns[key] = c
+		}
+		t.mu.Unlock()
+		return c.roundTrip(req, order)
+	}
+	return t.roundTrip1(newH1Conn(conn), key, req, order, proxy)
+}
+
+// nothingWrittenError is the error of a request which failed before any of
+// it was written to the connection.
+type nothingWrittenError struct {
+	error
+}
+
+// rewindRequest returns the request to send again after it failed with err
+// on a reused connection. A request is sent again when nothing was written,
+// or when its method is idempotent and its body can be read again. Otherwise
+// err is returned.
+func rewindRequest(req *http.Request, err error) (*http.Request, error) {
+	if _, ok := err.(nothingWrittenError); ok {
+		return req, nil
+	}
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+	default:
+		return req, err
+	}
+	if requestBody(req) == nil {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return req, err
+	}
+	body, gerr := req.GetBody()
+	if gerr != nil {
+		return req, err
+	}
+	closeBody(req)
+	r := *req
+	r.Body = body
+	return &r, nil
+}
+
+// CloseIdleConnections closes the connections which are not in use.
+func (t *OrderedTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, conns := range t.idle {
+		for _, c := range conns {
+			c.conn.Close()
+		}
+	}
+	t.idle = nil
+	for key, c := range t.conns {
+		if !c.busy {
+			c.conn.Close()
+			delete(t.conns, key)
+		}
+	}
+}
+
+// dial connects to the server of the request, through the proxy when it is
+// not nil. Returns the connection and the negotiated protocol.
+func (t *OrderedTransport) dial(req *http.Request, proxy *url.URL, addr string) (net.Conn, string, error) {
+	ctx := req.Context()
+	dial := t.DialContext
+	if dial == nil {
+		d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		dial = d.DialContext
+	}
+	target := addr
+	if proxy != nil {
+		if proxy.Scheme != "http" {
+			return nil, "", fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+		}
+		target = canonicalAddr(proxy)
+	}
+	conn, err := dial(ctx, "tcp", target)
+	if err != nil {
+		return nil, "", err
+	}
+	if req.URL.Scheme == "http" {
+		return conn, "http/1.1", nil
+	}
+	if proxy != nil {
+		if err := connectTunnel(conn, proxy, addr); err != nil {
+			conn.Close()
+			return nil, "", err
+		}
+	}
+
+	cfg := &tls.Config{}
+	if t.TLSClientConfig != nil {
+		cfg = t.TLSClientConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = req.URL.Hostname()
+	}
+	cfg.NextProtos = []string{"h2", "http/1.1"}
+	if t.DisableHTTP2 {
+		cfg.NextProtos = []string{"http/1.1"}
+	}
+	tc := tls.Client(conn, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+	return tc, tc.ConnectionState().NegotiatedProtocol, nil
+}
+
+// connectTunnel asks the proxy on conn to open a tunnel to addr.
+func connectTunnel(conn net.Conn, proxy *url.URL, addr string) error {
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if auth := proxyAuthorization(proxy); auth != "" {
+		req += "Proxy-Authorization: " + auth + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("proxy refused the connection to %s: %s", addr, resp.Status)
+	}
+	return nil
+}
+
+// proxyAuthorization returns the Proxy-Authorization header for the user
+// in the proxy URL.
+func proxyAuthorization(proxy *url.URL) string {
+	if proxy == nil || proxy.User == nil {
+		return ""
+	}
+	pass, _ := proxy.User.Password()
+	auth := proxy.User.Username() + ":" + pass
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+}
+
+// getIdle takes an idle HTTP/1.1 connection out of the pool.
+func (t *OrderedTransport) getIdle(key string) *h1Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := t.idle[key]
+	if len(conns) == 0 {
+		return nil
+	}
+	c := conns[len(conns)-1]
+	t.idle[key] = conns[:len(conns)-1]
+	return c
+}
+
+// putIdle puts an HTTP/1.1 connection back in the pool.
+func (t *OrderedTransport) putIdle(key string, c *h1Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.idle == nil {
+		t.idle = make(map[string][]*h1Conn)
+	}
+	t.idle[key] = append(t.idle[key], c)
+}
+
+// getH2 returns the HTTP/2 connection for the key when it is not in use.
+func (t *OrderedTransport) getH2(key string) *h2Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.conns[key]
+	if c == nil || c.busy || c.broken {
+		return nil
+	}
+	c.busy = true
+	return c
+}
+
+// releaseH2 marks the HTTP/2 connection as no longer in use. It is closed
+// when it is broken or was not pooled.
+func (t *OrderedTransport) releaseH2(c *h2Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c.busy = false
+	if c.broken || t.conns[c.key] != c {
+		c.conn.Close()
+		if t.conns[c.key] == c {
+			delete(t.conns, c.key)
+		}
+	}
+}
+
+// h1Conn is an HTTP/1.1 connection.
+type h1Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+	cw   *writeCounter
+}
+
+// newH1Conn creates and returns a new *h1Conn type.
+func newH1Conn(conn net.Conn) *h1Conn {
+	cw := &writeCounter{w: conn}
+	return &h1Conn{
+		conn: conn,
+		br:   bufio.NewReader(conn),
+		bw:   bufio.NewWriter(cw),
+		cw:   cw,
+	}
+}
+
+// writeCounter counts the bytes written to a connection.
+type writeCounter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements the io.Writer interface.
+func (w *writeCounter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// roundTrip1 sends the request over the HTTP/1.1 connection.
+func (t *OrderedTransport) roundTrip1(c *h1Conn, key string, req *http.Request, order []string, proxy *url.URL) (*http.Response, error) {
+	stop := watchContext(req.Context(), c.conn)
+	uri := req.URL.RequestURI()
+	if proxy != nil && req.URL.Scheme == "http" {
+		u := *req.URL
+		u.User = nil
+		u.Fragment = ""
+		uri = u.String()
+	} else {
+		proxy = nil
+	}
+	body, length := requestBody(req), requestLength(req)
+	fmt.Fprintf(c.bw, "%s %s HTTP/1.1\r\n", req.Method, uri)
+	for _, f := range requestFields(req, order, length, false) {
+		fmt.Fprintf(c.bw, "%s: %s\r\n", f.name, f.value)
+	}
+	if auth := proxyAuthorization(proxy); auth != "" {
+		fmt.Fprintf(c.bw, "Proxy-Authorization: %s\r\n", auth)
+	}
+	c.bw.WriteString("\r\n")
+	start := c.cw.n
+	if err := c.bw.Flush(); err != nil {
+		stop()
+		c.conn.Close()
+		if c.cw.n == start {
+			return nil, nothingWrittenError{err}
+		}
+		return nil, err
+	}
+	if body != nil {
+		if err := writeBody1(c.bw, body, length); err != nil {
+			stop()
+			c.conn.Close()
+			return nil, err
+		}
+	}
+
+	// Interim responses such as 100 Continue and 103 Early Hints are
+	// skipped, and the final response is read after them.
+	var resp *http.Response
+	for {
+		var err error
+		resp, err = http.ReadResponse(c.br, req)
+		if err != nil {
+			stop()
+			c.conn.Close()
+			return nil, err
+		}
+		if resp.StatusCode < 100 || resp.StatusCode >= 200 || resp.StatusCode == http.StatusSwitchingProtocols {
+			break
+		}
+	}
+	reuse := !resp.Close && !req.Close
+	done := func(eof bool) {
+		stop()
+		if eof && reuse {
+			t.putIdle(key, c)
+		} else {
+			c.conn.Close()
+		}
+	}
+	if resp.Body == http.NoBody {
+		done(true)
+	} else {
+		resp.Body = &h1Body{ReadCloser: resp.Body, done: done}
+	}
+	return resp, nil
+}
+
+// writeBody1 writes the request body, chunked when its length is not known.
+func writeBody1(bw *bufio.Writer, body io.Reader, length int64) error {
+	if length >= 0 {
+		if _, err := io.Copy(bw, body); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	cw := httputil.NewChunkedWriter(bw)
+	if _, err := io.Copy(cw, body); err != nil {
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	bw.WriteString("\r\n")
+	return bw.Flush()
+}
+
+// h1Body is the body of an HTTP/1.1 response, which gives the connection
+// back once it has been read.
+type h1Body struct {
+	io.ReadCloser
+	done func(eof bool)
+	once sync.Once
+}
+
+// Read implements the io.Reader interface.
+func (b *h1Body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(func() { b.done(true) })
+	}
+	return n, err
+}
+
+// Close implements the io.Closer interface.
+func (b *h1Body) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(false) })
+	return err
+}
+
+// headerField is a request header written by an OrderedTransport.
+type headerField struct {
+	name, value string
+}
+
+// requestFields returns the request headers in the order they are written.
+// HTTP/2 header names are lower case, and the connection specific headers
+// are left out. The length of the body is -1 when it is not known.
+func requestFields(req *http.Request, order []string, length int64, h2 bool) []headerField {
+	h := make(http.Header, len(req.Header)+2)
+	for name, values := range req.Header {
+		h[name] = values
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if !h2 {
+		setHeader(h, "Host", host)
+		if req.Close {
+			setHeader(h, "Connection", "close")
+		}
+	}
+	switch {
+	case length > 0 || length == 0 && (req.Method == "POST" || req.Method == "PUT" || req.Method == "PATCH"):
+		setHeader(h, "Content-Length", strconv.FormatInt(length, 10))
+	case length < 0 && !h2:
+		setHeader(h, "Transfer-Encoding", "chunked")
+	}
+
+	rank := func(name string) int {
+		for i, o := range order {
+			if strings.EqualFold(o, name) {
+				return i
+			}
+		}
+		if strings.EqualFold(name, "Host") {
+			return -1
+		}
+		return len(order)
+	}
+	names := make([]string, 0, len(h))
+	for name := range h {
+		if h2 && isConnectionHeader(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		ra, rb := rank(names[a]), rank(names[b])
+		if ra != rb {
+			return ra < rb
+		}
+		return names[a] < names[b]
+	})
+
+	var fields []headerField
+	if h2 {
+		path := req.URL.RequestURI()
+		pseudo := map[string]string{
+			":authority": host,
+			":method":    req.Method,
+			":path":      path,
+			":scheme":    req.URL.Scheme,
+		}
+		for _, name := range pseudoHeaderOrder(order) {
+			fields = append(fields, headerField{name, pseudo[name]})
+		}
+	}
+	for _, name := range names {
+		spelling := name
+		if r := rank(name); r >= 0 && r < len(order) {
+			spelling = order[r]
+		}
+		if h2 {
+			spelling = strings.ToLower(spelling)
+		}
+		for _, value := range h[name] {
+			value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+			fields = append(fields, headerField{spelling, value})
+		}
+	}
+	return fields
+}
+
+// pseudoHeaderOrder returns the order of the HTTP/2 pseudo headers. The
+// pseudo headers missing from the header order follow in the default order.
+func pseudoHeaderOrder(order []string) []string {
+	var pseudo []string
+	seen := map[string]bool{}
+	for _, name := range order {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, ":") && !seen[name] {
+			for _, d := range defaultPseudoHeaderOrder {
+				if d == name {
+					pseudo = append(pseudo, name)
+					seen[name] = true
+				}
+			}
+		}
+	}
+	for _, name := range defaultPseudoHeaderOrder {
+		if !seen[name] {
+			pseudo = append(pseudo, name)
+		}
+	}
+	return pseudo
+}
+
+// isConnectionHeader returns true for the headers which are not allowed in
+// HTTP/2 requests.
+func isConnectionHeader(name string) bool {
+	switch strings.ToLower(name) {
+	case "host", "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+		return true
+	}
+	return false
+}
+
+// setHeader sets the header to the given values, replacing the values of the
+// header spelled with any casing. The name is kept as it is given.
+func setHeader(h http.Header, name string, values ...string) {
+	delHeader(h, name)
+	h[name] = values
+}
+
+// delHeader removes the header spelled with any casing.
+func delHeader(h http.Header, name string) {
+	for key := range h {
+		if strings.EqualFold(key, name) {
+			delete(h, key)
+		}
+	}
+}
+
+// requestBody returns the request body, or nil when there is none.
+func requestBody(req *http.Request) io.Reader {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	return req.Body
+}
+
+// requestLength returns the length of the request body, or -1 when it is
+// not known.
+func requestLength(req *http.Request) int64 {
+	switch {
+	case requestBody(req) == nil:
+		return 0
+	case req.ContentLength > 0:
+		return req.ContentLength
+	}
+	return -1
+}
+
+// readBody reads and closes the request body.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return ioutil.ReadAll(req.Body)
+}
+
+// closeBody closes the request body.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// canonicalAddr returns the host and port of the URL.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// watchContext closes the connection when the context is done. The returned
+// function stops watching.
+func watchContext(ctx context.Context, conn net.Conn) func() {
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	done := make(chan struct{})
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+			case <-done:
+			}
+		}()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			conn.SetDeadline(time.Time{})
+		})
+	}
+}
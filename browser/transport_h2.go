@@ -0,0 +1,398 @@
+package browser
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// The settings sent when opening an HTTP/2 connection, which are the
+// settings of Chrome.
+const (
+	h2InitialWindowSize  = 6291456
+	h2MaxHeaderListSize  = 262144
+	h2ConnWindowIncrease = 15663105
+)
+
+// h2Conn is an HTTP/2 connection carrying one request at a time.
+type h2Conn struct {
+	t    *OrderedTransport
+	key  string
+	conn net.Conn
+	bw   *bufio.Writer
+	cw   *writeCounter
+	fr   *http2.Framer
+
+	encBuf bytes.Buffer
+	enc    *hpack.Encoder
+
+	// busy and broken are guarded by the mutex of the transport.
+	busy   bool
+	broken bool
+
+	nextID        uint32
+	maxFrameSize  uint32
+	initialWindow int32
+	connWindow    int32
+	streamWindow  int32
+}
+
+// newH2Conn starts an HTTP/2 connection over conn.
+func newH2Conn(t *OrderedTransport, key string, conn net.Conn) (*h2Conn, error) {
+	cw := &writeCounter{w: conn}
+	c := &h2Conn{
+		t:             t,
+		key:           key,
+		conn:          conn,
+		bw:            bufio.NewWriter(cw),
+		cw:            cw,
+		nextID:        1,
+		maxFrameSize:  16384,
+		initialWindow: 65535,
+		connWindow:    65535,
+	}
+	c.enc = hpack.NewEncoder(&c.encBuf)
+	c.fr = http2.NewFramer(c.bw, bufio.NewReader(conn))
+	c.fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	c.fr.MaxHeaderListSize = h2MaxHeaderListSize
+
+	c.bw.WriteString(http2.ClientPreface)
+	c.fr.WriteSettings(
+		http2.Setting{ID: http2.SettingEnablePush, Val: 0},
+		http2.Setting{ID: http2.SettingInitialWindowSize, Val: h2InitialWindowSize},
+		http2.Setting{ID: http2.SettingMaxHeaderListSize, Val: h2MaxHeaderListSize},
+	)
+	c.fr.WriteWindowUpdate(0, h2ConnWindowIncrease)
+	if err := c.bw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// roundTrip sends the request as a new stream. The connection must have been
+// marked busy, it is released once the response body has been read.
+func (c *h2Conn) roundTrip(req *http.Request, order []string) (*http.Response, error) {
+	stop := watchContext(req.Context(), c.conn)
+	fail := func(err error) (*http.Response, error) {
+		stop()
+		c.markBroken()
+		c.t.releaseH2(c)
+		return nil, err
+	}
+
+	id := c.nextID
+	c.nextID += 2
+	c.streamWindow = c.initialWindow
+
+	body := requestBody(req)
+	c.encBuf.Reset()
+	for _, f := range requestFields(req, order, requestLength(req), true) {
+		c.enc.WriteField(hpack.HeaderField{Name: f.name, Value: f.value})
+	}
+	block := c.encBuf.Bytes()
+	for first := true; first || len(block) > 0; first = false {
+		chunk := block
+		if len(chunk) > int(c.maxFrameSize) {
+			chunk = chunk[:c.maxFrameSize]
+		}
+		block = block[len(chunk):]
+		var err error
+		if first {
+			err = c.fr.WriteHeaders(http2.HeadersFrameParam{
+				StreamID:      id,
+				BlockFragment: chunk,
+				EndStream:     body == nil,
+				EndHeaders:    len(block) == 0,
+			})
+		} else {
+			err = c.fr.WriteContinuation(id, len(block) == 0, chunk)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	start := c.cw.n
+	if err := c.bw.Flush(); err != nil {
+		if c.cw.n == start {
+			err = nothingWrittenError{err}
+		}
+		return fail(err)
+	}
+
+	var early http2.Frame
+	if body != nil {
+		var err error
+		if early, err = c.writeBody(id, body); err != nil {
+			return fail(err)
+		}
+	}
+
+	for {
+		f := early
+		early = nil
+		if f == nil {
+			var err error
+			if f, err = c.next(id); err != nil {
+				return fail(err)
+			}
+			if f == nil {
+				continue
+			}
+		}
+		hf, ok := f.(*http2.MetaHeadersFrame)
+		if !ok {
+			return fail(fmt.Errorf("http2: unexpected %v frame before the response headers", f.Header().Type))
+		}
+		status, err := strconv.Atoi(hf.PseudoValue("status"))
+		if err != nil {
+			return fail(fmt.Errorf("http2: invalid response status %q", hf.PseudoValue("status")))
+		}
+		if status >= 100 && status < 200 {
+			continue
+		}
+		return c.response(req, id, status, hf, stop), nil
+	}
+}
+
+// markBroken marks the connection as broken, so it is not used for another
+// request.
+func (c *h2Conn) markBroken() {
+	c.t.mu.Lock()
+	c.broken = true
+	c.t.mu.Unlock()
+}
+
+// writeBody sends the request body as DATA frames within the flow control
+// windows. The response may come before the whole body has been sent, e.g.
+// when the server refuses it, in which case its first frame is returned.
+func (c *h2Conn) writeBody(id uint32, body io.Reader) (http2.Frame, error) {
+	buf := make([]byte, c.maxFrameSize)
+	var data []byte
+	eof := false
+	for {
+		if len(data) == 0 && !eof {
+			n, err := io.ReadFull(body, buf)
+			switch err {
+			case nil:
+			case io.EOF, io.ErrUnexpectedEOF:
+				eof = true
+			default:
+				return nil, err
+			}
+			data = buf[:n]
+		}
+		n := len(data)
+		if n > int(c.maxFrameSize) {
+			n = int(c.maxFrameSize)
+		}
+		if n > int(c.connWindow) {
+			n = int(c.connWindow)
+		}
+		if n > int(c.streamWindow) {
+			n = int(c.streamWindow)
+		}
+		if n <= 0 && len(data) > 0 {
+			f, err := c.next(id)
+			if err != nil {
+				return nil, err
+			}
+			if f != nil {
+				return f, nil
+			}
+			continue
+		}
+		end := eof && n == len(data)
+		if err := c.fr.WriteData(id, end, data[:n]); err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		c.connWindow -= int32(n)
+		c.streamWindow -= int32(n)
+		if err := c.bw.Flush(); err != nil {
+			return nil, err
+		}
+		if end {
+			return nil, nil
+		}
+	}
+}
+
+// response builds the response from the response headers.
+func (c *h2Conn) response(req *http.Request, id uint32, status int, hf *http2.MetaHeadersFrame, stop func()) *http.Response {
+	resp := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        make(http.Header),
+		ContentLength: -1,
+		Request:       req,
+	}
+	for _, f := range hf.RegularFields() {
+		resp.Header.Add(http.CanonicalHeaderKey(f.Name), f.Value)
+	}
+	if cl, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = cl
+	}
+	if tc, ok := c.conn.(*tls.Conn); ok {
+		state := tc.ConnectionState()
+		resp.TLS = &state
+	}
+	b := &h2Body{c: c, id: id, stop: stop}
+	if hf.StreamEnded() {
+		b.finish(true)
+		resp.Body = http.NoBody
+		resp.ContentLength = 0
+	} else {
+		resp.Body = b
+	}
+	return resp
+}
+
+// next reads the next frame of the stream. The frames of the connection are
+// handled, and nil is returned when the frame was not for the stream.
+func (c *h2Conn) next(id uint32) (http2.Frame, error) {
+	f, err := c.fr.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	switch f := f.(type) {
+	case *http2.SettingsFrame:
+		if f.IsAck() {
+			return nil, nil
+		}
+		f.ForeachSetting(func(s http2.Setting) error {
+			switch s.ID {
+			case http2.SettingMaxFrameSize:
+				c.maxFrameSize = s.Val
+			case http2.SettingInitialWindowSize:
+				c.streamWindow += int32(s.Val) - c.initialWindow
+				c.initialWindow = int32(s.Val)
+			}
+			return nil
+		})
+		c.fr.WriteSettingsAck()
+		return nil, c.bw.Flush()
+	case *http2.PingFrame:
+		if !f.IsAck() {
+			c.fr.WritePing(true, f.Data)
+			return nil, c.bw.Flush()
+		}
+	case *http2.WindowUpdateFrame:
+		if f.StreamID == 0 {
+			c.connWindow += int32(f.Increment)
+		} else if f.StreamID == id {
+			c.streamWindow += int32(f.Increment)
+		}
+	case *http2.GoAwayFrame:
+		c.markBroken()
+		if id > f.LastStreamID {
+			return nil, fmt.Errorf("http2: server sent GOAWAY with %v", f.ErrCode)
+		}
+	case *http2.RSTStreamFrame:
+		if f.StreamID == id {
+			return nil, fmt.Errorf("http2: stream reset by the server with %v", f.ErrCode)
+		}
+	case *http2.DataFrame:
+		if f.StreamID == id {
+			return f, nil
+		}
+		// The data of a cancelled stream still counts against the window
+		// of the connection.
+		if f.Length > 0 {
+			c.fr.WriteWindowUpdate(0, f.Length)
+			return nil, c.bw.Flush()
+		}
+	case *http2.MetaHeadersFrame:
+		if f.StreamID == id {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+// h2Body is the body of an HTTP/2 response, which is read from the
+// connection as it is needed.
+type h2Body struct {
+	c      *h2Conn
+	id     uint32
+	stop   func()
+	buf    []byte
+	ended  bool
+	closed bool
+	once   sync.Once
+}
+
+// Read implements the io.Reader interface.
+func (b *h2Body) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, fmt.Errorf("http2: read on closed response body")
+	}
+	for len(b.buf) == 0 {
+		if b.ended {
+			b.finish(true)
+			return 0, io.EOF
+		}
+		f, err := b.c.next(b.id)
+		if err != nil {
+			b.c.markBroken()
+			b.finish(false)
+			return 0, err
+		}
+		switch f := f.(type) {
+		case *http2.DataFrame:
+			b.buf = append(b.buf[:0], f.Data()...)
+			b.ended = f.StreamEnded()
+			if f.Length > 0 {
+				b.c.fr.WriteWindowUpdate(0, f.Length)
+				if !b.ended {
+					b.c.fr.WriteWindowUpdate(b.id, f.Length)
+				}
+				if err := b.c.bw.Flush(); err != nil {
+					b.c.markBroken()
+					b.finish(false)
+					return 0, err
+				}
+			}
+		case *http2.MetaHeadersFrame:
+			// Trailers are dropped.
+			b.ended = f.StreamEnded()
+		}
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+// Close implements the io.Closer interface. The stream is cancelled when
+// the body has not been read to the end.
+func (b *h2Body) Close() error {
+	if !b.ended {
+		b.c.fr.WriteRSTStream(b.id, http2.ErrCodeCancel)
+		if b.c.bw.Flush() != nil {
+			b.c.markBroken()
+		}
+	}
+	b.closed = true
+	b.finish(b.ended)
+	return nil
+}
+
+// finish releases the connection once.
+func (b *h2Body) finish(ended bool) {
+	b.once.Do(func() {
+		b.ended = ended
+		b.stop()
+		b.c.t.releaseH2(b.c)
+	})
+}
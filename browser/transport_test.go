@@ -0,0 +1,316 @@
+package browser
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/headzoo/ut"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestOrderedTransport(t *testing.T) {
+	ut.Run(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ut.AssertNil(err)
+	defer ln.Close()
+	requests := make(chan []string, 2)
+	conns := 0
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns++
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					var lines []string
+					for {
+						line, err := br.ReadString('\n')
+						if err != nil {
+							return
+						}
+						line = strings.TrimRight(line, "\r\n")
+						if line == "" {
+							break
+						}
+						lines = append(lines, line)
+					}
+					requests <- lines
+					body := "<html><title>Ordered</title></html>"
+					fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+				}
+			}()
+		}
+	}()
+
+	bow := newDefaultTestBrowser()
+	bow.SetUserAgent("Surf")
+	bow.SetHeaderOrder([]string{"User-Agent", "x-custom", "Accept", "Host"})
+	bow.AddRequestHeader("X-Custom", "0")
+	bow.AddRequestHeader("x-custom", "1")
+	bow.AddRequestHeader("accept", "*/*")
+	bow.AddRequestHeader("upgrade-insecure-requests", "1")
+	ut.AssertEquals("1", bow.GetRequestHeader("X-CUSTOM"))
+
+	u := "http://" + ln.Addr().String() + "/"
+	err = bow.Open(u)
+	ut.AssertNil(err)
+	ut.AssertEquals("Ordered", bow.Title())
+	lines := <-requests
+	ut.AssertEquals([]string{
+		"GET / HTTP/1.1",
+		"User-Agent: Surf",
+		"x-custom: 1",
+		"Accept: */*",
+		"Host: " + ln.Addr().String(),
+		"upgrade-insecure-requests: 1",
+	}, lines)
+
+	// The connection is kept alive.
+	err = bow.Open(u)
+	ut.AssertNil(err)
+	<-requests
+	ut.AssertEquals(1, conns)
+
+	bow.DelRequestHeader("X-CUSTOM")
+	ut.AssertEquals("", bow.GetRequestHeader("x-custom"))
+}
+
+func TestOrderedTransportHTTP2(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Proto", r.Proto)
+		fmt.Fprintf(w, "%s %s %s %s", r.Method, r.URL.Path, r.Header.Get("X-Custom"), b)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	ot := NewOrderedTransport(ts.Client().Transport.(*http.Transport))
+	client := &http.Client{Transport: ot}
+	req, err := http.NewRequest("POST", ts.URL+"/form", strings.NewReader("a=1"))
+	ut.AssertNil(err)
+	req.Header.Set("X-Custom", "yes")
+	req = req.WithContext(WithHeaderOrder(req.Context(), []string{":method", ":path", "X-Custom"}))
+	resp, err := client.Do(req)
+	ut.AssertNil(err)
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	ut.AssertNil(err)
+	ut.AssertEquals(200, resp.StatusCode)
+	ut.AssertEquals("HTTP/2.0", resp.Header.Get("X-Proto"))
+	ut.AssertEquals("POST /form yes a=1", string(b))
+
+	resp, err = client.Get(ts.URL + "/again")
+	ut.AssertNil(err)
+	b, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	ut.AssertNil(err)
+	ut.AssertEquals("GET /again  ", string(b))
+	ut.AssertEquals(1, len(ot.conns))
+}
+
+func TestOrderedTransportPseudoHeaders(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+	ts.StartTLS()
+	defer ts.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: ts.TLS.Certificates,
+		NextProtos:   []string{"h2"},
+	})
+	ut.AssertNil(err)
+	defer ln.Close()
+	fields := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		preface := make([]byte, len(http2.ClientPreface))
+		if _, err := io.ReadFull(conn, preface); err != nil {
+			return
+		}
+		fr := http2.NewFramer(conn, conn)
+		fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+		fr.WriteSettings()
+		for {
+			f, err := fr.ReadFrame()
+			if err != nil {
+				return
+			}
+			if hf, ok := f.(*http2.MetaHeadersFrame); ok {
+				var names []string
+				for _, f := range hf.Fields {
+					names = append(names, f.Name)
+				}
+				fields <- names
+				buf := &bytes.Buffer{}
+				hpack.NewEncoder(buf).WriteField(hpack.HeaderField{Name: ":status", Value: "204"})
+				fr.WriteHeaders(http2.HeadersFrameParam{
+					StreamID:      hf.StreamID,
+					BlockFragment: buf.Bytes(),
+					EndStream:     true,
+					EndHeaders:    true,
+				})
+			}
+		}
+	}()
+
+	ot := NewOrderedTransport(ts.Client().Transport.(*http.Transport))
+	req, err := http.NewRequest("GET", "https://"+ln.Addr().String()+"/", nil)
+	ut.AssertNil(err)
+	req.Header["sec-ch-ua"] = []string{"x"}
+	req.Header.Set("User-Agent", "Surf")
+	req.Header.Set("Connection", "keep-alive")
+	order := []string{":method", ":path", ":authority", ":scheme", "User-Agent", "sec-ch-ua"}
+	req = req.WithContext(WithHeaderOrder(req.Context(), order))
+	resp, err := ot.RoundTrip(req)
+	ut.AssertNil(err)
+	resp.Body.Close()
+	ut.AssertEquals(204, resp.StatusCode)
+	ut.AssertEquals([]string{":method", ":path", ":authority", ":scheme", "user-agent", "sec-ch-ua"}, <-fields)
+}
+
+func TestOrderedTransportStreaming(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %v %d", r.Proto, r.TransferEncoding, len(b))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	for _, h2 := range []bool{false, true} {
+		tr := ts.Client().Transport.(*http.Transport)
+		ot := NewOrderedTransport(tr)
+		ot.DisableHTTP2 = !h2
+		pr, pw := io.Pipe()
+		go func() {
+			for i := 0; i < 10; i++ {
+				pw.Write(bytes.Repeat([]byte("x"), 10000))
+			}
+			pw.Close()
+		}()
+		req, err := http.NewRequest("POST", ts.URL+"/upload", pr)
+		ut.AssertNil(err)
+		resp, err := ot.RoundTrip(req)
+		ut.AssertNil(err)
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if h2 {
+			ut.AssertEquals("HTTP/2.0 [] 100000", string(b))
+		} else {
+			ut.AssertEquals("HTTP/1.1 [chunked] 100000", string(b))
+		}
+	}
+}
+
+func TestOrderedTransportRetry(t *testing.T) {
+	ut.Run(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ut.AssertNil(err)
+	defer ln.Close()
+	closed := make(chan struct{}, 4)
+	requests := 0
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Every connection serves one request, and is then closed
+			// while the client keeps it for the next one.
+			go func() {
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err == nil {
+					ioutil.ReadAll(req.Body)
+					requests++
+					io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+				}
+				conn.Close()
+				closed <- struct{}{}
+			}()
+		}
+	}()
+
+	ot := NewOrderedTransport(nil)
+	u := "http://" + ln.Addr().String() + "/"
+	send := func(method string) error {
+		req, _ := http.NewRequest(method, u, strings.NewReader("a=1"))
+		resp, err := ot.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		ioutil.ReadAll(resp.Body)
+		return resp.Body.Close()
+	}
+	ut.AssertNil(send("GET"))
+	<-closed
+
+	// A GET is sent again on a new connection.
+	ut.AssertNil(send("GET"))
+	<-closed
+	ut.AssertEquals(2, requests)
+
+	// A POST which may have been received is not.
+	ut.AssertNotNil(send("POST"))
+	ut.AssertEquals(2, requests)
+}
+
+func TestOrderedTransportEarlyHints(t *testing.T) {
+	ut.Run(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ut.AssertNil(err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for i := 1; ; i++ {
+					if _, err := http.ReadRequest(br); err != nil {
+						return
+					}
+					body := fmt.Sprintf("body%d", i)
+					fmt.Fprintf(conn, "HTTP/1.1 103 Early Hints\r\nLink: </style.css>; rel=preload\r\n\r\n")
+					fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+				}
+			}()
+		}
+	}()
+
+	ot := NewOrderedTransport(nil)
+	u := "http://" + ln.Addr().String() + "/"
+	for _, want := range []string{"body1", "body2"} {
+		req, _ := http.NewRequest("GET", u, nil)
+		resp, err := ot.RoundTrip(req)
+		ut.AssertNil(err)
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		ut.AssertEquals(200, resp.StatusCode)
+		ut.AssertEquals(want, string(b))
+	}
+}
@@ -1,4 +1,13 @@
 // Package errors contains error types specific to the Surf library.
+//
+// Every error type wraps its underlying cause, so the standard library
+// functions errors.Is and errors.As work on Surf errors. Each type also
+// matches one of the sentinel values declared below, which allows callers to
+// test for a class of failure without a type assertion:
+//
+//	if errors.Is(err, errors.ErrElementNotFound) {
+//		...
+//	}
 package errors
 
 import (
@@ -6,107 +15,384 @@ import (
 	"fmt"
 )
 
+// Sentinel values matched by the Surf error types through errors.Is.
+var (
+	// ErrPageNotFound is matched by PageNotFound errors.
+	ErrPageNotFound = errors.New("page not found")
+
+	// ErrLinkNotFound is matched by LinkNotFound errors.
+	ErrLinkNotFound = errors.New("link not found")
+
+	// ErrAttributeNotFound is matched by AttributeNotFound errors.
+	ErrAttributeNotFound = errors.New("attribute not found")
+
+	// ErrLocation is matched by Location errors.
+	ErrLocation = errors.New("location not followed")
+
+	// ErrPageNotLoaded is matched by PageNotLoaded errors.
+	ErrPageNotLoaded = errors.New("page not loaded")
+
+	// ErrElementNotFound is matched by ElementNotFound errors.
+	ErrElementNotFound = errors.New("element not found")
+
+	// ErrAmbiguous is matched by Ambiguous errors.
+	ErrAmbiguous = errors.New("ambiguous match")
+
+	// ErrInvalidFormValue is matched by InvalidFormValue errors.
+	ErrInvalidFormValue = errors.New("invalid form value")
+
+	// ErrRequest is matched by Request errors.
+	ErrRequest = errors.New("request failed")
+
+	// ErrTimeout is matched by Timeout errors.
+	ErrTimeout = errors.New("request timed out")
+
+	// ErrDNS is matched by DNS errors.
+	ErrDNS = errors.New("host lookup failed")
+
+	// ErrConnectionRefused is matched by ConnectionRefused errors.
+	ErrConnectionRefused = errors.New("connection refused")
+
+	// ErrTLS is matched by TLS errors.
+	ErrTLS = errors.New("tls handshake failed")
+
+	// ErrServiceUnavailable is matched by Request errors caused by a proxy
+	// answering with 503 Service Unavailable.
+	ErrServiceUnavailable = errors.New("service unavailable")
+
+	// ErrChallenge is matched by Challenge errors.
+	ErrChallenge = errors.New("challenge not solved")
+)
+
+// Is reports whether any error in err's chain matches target.
+// It is the same as the standard library errors.Is.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target.
+// It is the same as the standard library errors.As.
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+// Unwrap returns the result of calling the Unwrap method on err.
+// It is the same as the standard library errors.Unwrap.
+func Unwrap(err error) error {
+	return errors.Unwrap(err)
+}
+
+// details holds the fields shared by every Surf error type. Fields that do
+// not apply to a failure are left empty.
+type details struct {
+	// Cause is the underlying error, or nil.
+	Cause error
+
+	// URL is the URL involved in the failure.
+	URL string
+
+	// Method is the HTTP method of the failed request.
+	Method string
+
+	// Selector is the expression used to find the element involved.
+	Selector string
+
+	msg  string
+	kind error
+}
+
+// newDetails formats the message of an error matching the given sentinel.
+func newDetails(kind error, msg string, a ...interface{}) details {
+	return details{
+		msg:  fmt.Sprintf(msg, a...),
+		kind: kind,
+	}
+}
+
+// Error returns the error message, followed by the message of the cause.
+func (e details) Error() string {
+	switch {
+	case e.Cause == nil:
+		return e.msg
+	case e.msg == "":
+		return e.Cause.Error()
+	}
+	return e.msg + ": " + e.Cause.Error()
+}
+
+// Unwrap returns the underlying cause.
+func (e details) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether the error matches the given sentinel value.
+func (e details) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
 // Error represents any generic error.
 type Error struct {
-	error
+	details
 }
 
 // New creates and returns an Error type.
 func New(msg string, a ...interface{}) Error {
-	msg = fmt.Sprintf(msg, a...)
 	return Error{
-		error: errors.New(msg),
+		details: newDetails(nil, msg, a...),
+	}
+}
+
+// Wrap creates and returns an Error type with the given cause.
+func Wrap(cause error, msg string, a ...interface{}) Error {
+	e := Error{
+		details: newDetails(nil, msg, a...),
 	}
+	e.Cause = cause
+	return e
 }
 
 // PageNotFound represents a failed attempt to visit a page because the page
 // does not exist.
 type PageNotFound struct {
-	error
+	details
 }
 
 // NewPageNotFound creates and returns a NotFound type.
 func NewPageNotFound(msg string, a ...interface{}) PageNotFound {
-	msg = fmt.Sprintf("Not Found: "+msg, a...)
 	return PageNotFound{
-		error: errors.New(msg),
+		details: newDetails(ErrPageNotFound, "Not Found: "+msg, a...),
 	}
 }
 
 // LinkNotFound represents a failed attempt to follow a link on a page.
 type LinkNotFound struct {
-	error
+	details
 }
 
 // NewLinkNotFound creates and returns a LinkNotFound type.
 func NewLinkNotFound(msg string, a ...interface{}) LinkNotFound {
-	msg = fmt.Sprintf("Link Not Found: "+msg, a...)
 	return LinkNotFound{
-		error: errors.New(msg),
+		details: newDetails(ErrLinkNotFound, "Link Not Found: "+msg, a...),
 	}
 }
 
 // AttributeNotFound represents a failed attempt to read an element attribute.
 type AttributeNotFound struct {
-	error
+	details
 }
 
 // NewAttributeNotFound creates and returns a AttributeNotFound type.
 func NewAttributeNotFound(msg string, a ...interface{}) AttributeNotFound {
-	msg = fmt.Sprintf(msg, a...)
 	return AttributeNotFound{
-		error: errors.New(msg),
+		details: newDetails(ErrAttributeNotFound, msg, a...),
 	}
 }
 
 // Location represents a failed attempt to follow a Location header.
 type Location struct {
-	error
+	details
 }
 
 // NewLocation creates and returns a Location type.
 func NewLocation(msg string, a ...interface{}) Location {
-	msg = fmt.Sprintf(msg, a...)
 	return Location{
-		error: errors.New(msg),
+		details: newDetails(ErrLocation, msg, a...),
 	}
 }
 
 // PageNotLoaded represents a failed attempt to operate on a non-loaded page.
 type PageNotLoaded struct {
-	error
+	details
 }
 
 // NewPageNotLoaded creates and returns a PageNotLoaded type.
 func NewPageNotLoaded(msg string, a ...interface{}) PageNotLoaded {
-	msg = fmt.Sprintf("Page Not Loaded: "+msg, a...)
 	return PageNotLoaded{
-		error: errors.New(msg),
+		details: newDetails(ErrPageNotLoaded, "Page Not Loaded: "+msg, a...),
 	}
 }
 
 // ElementNotFound represents a failed attempt to operate on a non-existent page element.
 type ElementNotFound struct {
-	error
+	details
 }
 
 // NewElementNotFound creates and returns a ElementNotFound type.
 func NewElementNotFound(msg string, a ...interface{}) ElementNotFound {
-	msg = fmt.Sprintf(msg, a...)
 	return ElementNotFound{
-		error: errors.New(msg),
+		details: newDetails(ErrElementNotFound, msg, a...),
+	}
+}
+
+// Ambiguous represents a failed attempt to operate on an element when more
+// than one element matches.
+//
+// The candidates are held behind a pointer so the error can be compared
+// with ==, like the other errors of the package.
+type Ambiguous struct {
+	details
+	candidates *[]string
+}
+
+// NewAmbiguous creates and returns a Ambiguous type.
+func NewAmbiguous(msg string, a ...interface{}) Ambiguous {
+	return Ambiguous{
+		details: newDetails(ErrAmbiguous, msg, a...),
+	}
+}
+
+// Candidates returns the descriptions of the elements which match.
+func (e Ambiguous) Candidates() []string {
+	if e.candidates == nil {
+		return nil
 	}
+	return *e.candidates
+}
+
+// SetCandidates sets the descriptions of the elements which match.
+func (e *Ambiguous) SetCandidates(candidates []string) {
+	e.candidates = &candidates
 }
 
 // InvalidFormValue represents a failed attempt to set a form value that is not valid.
+//
+// The field names are held behind a pointer so the error can be compared
+// with ==, like the other errors of the package.
 type InvalidFormValue struct {
-	error
+	details
+	fields *[]string
 }
 
 // NewInvalidFormValue creates and returns a InvalidFormValue type.
 func NewInvalidFormValue(msg string, a ...interface{}) InvalidFormValue {
-	msg = fmt.Sprintf(msg, a...)
 	return InvalidFormValue{
-		error: errors.New(msg),
+		details: newDetails(ErrInvalidFormValue, msg, a...),
+	}
+}
+
+// Fields returns the names of the fields whose values are not valid.
+func (e InvalidFormValue) Fields() []string {
+	if e.fields == nil {
+		return nil
+	}
+	return *e.fields
+}
+
+// SetFields sets the names of the fields whose values are not valid.
+func (e *InvalidFormValue) SetFields(names []string) {
+	e.fields = &names
+}
+
+// Request represents an HTTP request that could not be completed.
+type Request struct {
+	details
+
+	// StatusCode is the status a proxy answered with when it refused the
+	// request, or zero.
+	StatusCode int
+}
+
+// NewRequest creates and returns a Request type wrapping the given cause.
+func NewRequest(cause error, method, url string) Request {
+	e := Request{
+		details: newDetails(ErrRequest, "%s %q", method, url),
+	}
+	e.Cause = cause
+	e.Method = method
+	e.URL = url
+	return e
+}
+
+// Is reports whether the error matches ErrRequest, or ErrServiceUnavailable
+// when a proxy refused the request with a 503 status.
+func (e Request) Is(target error) bool {
+	if target == ErrServiceUnavailable {
+		return e.StatusCode == 503
+	}
+	return e.details.Is(target)
+}
+
+// Timeout represents a request that did not complete before the client
+// timeout expired.
+type Timeout struct {
+	Request
+}
+
+// NewTimeout creates and returns a Timeout type wrapping the given cause.
+func NewTimeout(cause error, method, url string) Timeout {
+	return Timeout{
+		Request: NewRequest(cause, method, url),
+	}
+}
+
+// Is reports whether the error matches ErrTimeout or ErrRequest.
+func (e Timeout) Is(target error) bool {
+	return target == ErrTimeout || e.Request.Is(target)
+}
+
+// DNS represents a request that failed because the host name could not be
+// resolved.
+type DNS struct {
+	Request
+}
+
+// NewDNS creates and returns a DNS type wrapping the given cause.
+func NewDNS(cause error, method, url string) DNS {
+	return DNS{
+		Request: NewRequest(cause, method, url),
+	}
+}
+
+// Is reports whether the error matches ErrDNS or ErrRequest.
+func (e DNS) Is(target error) bool {
+	return target == ErrDNS || e.Request.Is(target)
+}
+
+// ConnectionRefused represents a request that failed because the remote host
+// refused the connection.
+type ConnectionRefused struct {
+	Request
+}
+
+// NewConnectionRefused creates and returns a ConnectionRefused type wrapping
+// the given cause.
+func NewConnectionRefused(cause error, method, url string) ConnectionRefused {
+	return ConnectionRefused{
+		Request: NewRequest(cause, method, url),
+	}
+}
+
+// Is reports whether the error matches ErrConnectionRefused or ErrRequest.
+func (e ConnectionRefused) Is(target error) bool {
+	return target == ErrConnectionRefused || e.Request.Is(target)
+}
+
+// TLS represents a request that failed during the TLS handshake, for example
+// because the certificate could not be verified.
+type TLS struct {
+	Request
+}
+
+// NewTLS creates and returns a TLS type wrapping the given cause.
+func NewTLS(cause error, method, url string) TLS {
+	return TLS{
+		Request: NewRequest(cause, method, url),
+	}
+}
+
+// Is reports whether the error matches ErrTLS or ErrRequest.
+func (e TLS) Is(target error) bool {
+	return target == ErrTLS || e.Request.Is(target)
+}
+
+// Challenge represents an anti-bot challenge page that could not be solved.
+type Challenge struct {
+	details
+}
+
+// NewChallenge creates and returns a Challenge type.
+func NewChallenge(msg string, a ...interface{}) Challenge {
+	return Challenge{
+		details: newDetails(ErrChallenge, msg, a...),
 	}
 }
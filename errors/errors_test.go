@@ -0,0 +1,58 @@
+package errors
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestSentinels(t *testing.T) {
+	ut.Run(t)
+
+	err := error(NewElementNotFound("Element not found matching expr '%s'.", "a.next"))
+	ut.AssertTrue(Is(err, ErrElementNotFound))
+	ut.AssertFalse(Is(err, ErrPageNotFound))
+	ut.AssertEquals("Element not found matching expr 'a.next'.", err.Error())
+
+	var enf ElementNotFound
+	ut.AssertTrue(As(err, &enf))
+
+	err = New("Generic error.")
+	ut.AssertFalse(Is(err, ErrElementNotFound))
+
+	amb := NewAmbiguous("2 links found with the text '%s'.", "Edit")
+	amb.SetCandidates([]string{"/edit/1", "/edit/2"})
+	err = amb
+	ut.AssertTrue(Is(err, ErrAmbiguous))
+	ut.AssertEquals("2 links found with the text 'Edit'.", err.Error())
+	ut.AssertEquals([]string{"/edit/1", "/edit/2"}, amb.Candidates())
+	ut.AssertTrue(err == err)
+
+	inv := NewInvalidFormValue("Form is not valid.")
+	inv.SetFields([]string{"user"})
+	err = inv
+	ut.AssertTrue(err == err)
+	ut.AssertEquals([]string{"user"}, inv.Fields())
+}
+
+func TestRequest(t *testing.T) {
+	ut.Run(t)
+
+	rerr := NewRequest(io.ErrUnexpectedEOF, "GET", "http://localhost/")
+	err := error(rerr)
+	ut.AssertTrue(Is(err, ErrRequest))
+	ut.AssertTrue(errors.Is(err, io.ErrUnexpectedEOF))
+	ut.AssertFalse(Is(err, ErrServiceUnavailable))
+	ut.AssertEquals(`GET "http://localhost/": unexpected EOF`, err.Error())
+	ut.AssertEquals("http://localhost/", rerr.URL)
+	ut.AssertEquals("GET", rerr.Method)
+
+	rerr.StatusCode = 503
+	ut.AssertTrue(Is(rerr, ErrServiceUnavailable))
+
+	wrapped := Wrap(rerr, "Login failed")
+	ut.AssertTrue(Is(wrapped, ErrRequest))
+	ut.AssertEquals(rerr, Unwrap(wrapped))
+}
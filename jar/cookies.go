@@ -1,11 +1,468 @@
 package jar
 
-import "net/http/cookiejar"
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// PublicSuffixes is the public suffix list used by the cookie jars to refuse
+// cookies set for a whole registry such as "co.uk". It defaults to the list
+// compiled into golang.org/x/net/publicsuffix.
+var PublicSuffixes cookiejar.PublicSuffixList = publicsuffix.List
 
 // New returns a new cookie jar.
 func NewMemoryCookies() *cookiejar.Jar {
 	// cookiejar.New returns an error, but it's always nil. Maybe it's there
 	// for future use or to conform to an interface?
-	jar, _ := cookiejar.New(nil)
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: PublicSuffixes})
 	return jar
 }
+
+// Site returns the registrable domain of the given host, which is the public
+// suffix plus one label, e.g. "example.co.uk" for "www.example.co.uk". The
+// host is returned as is when it is an IP address or a public suffix.
+func Site(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if isIP(host) {
+		return host
+	}
+	site, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return site
+}
+
+// isPublicSuffix returns true when the domain is listed in PublicSuffixes.
+func isPublicSuffix(domain string) bool {
+	if PublicSuffixes == nil {
+		return false
+	}
+	return PublicSuffixes.PublicSuffix(domain) == domain
+}
+
+// Cookie is a cookie stored in a CookieJar.
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+
+	// Domain is the domain the cookie is sent to, without a leading dot.
+	Domain string `json:"domain"`
+
+	// HostOnly is true when the cookie is only sent to Domain, and false
+	// when it is also sent to the subdomains of Domain.
+	HostOnly bool `json:"host_only"`
+
+	Path     string        `json:"path"`
+	Secure   bool          `json:"secure"`
+	HttpOnly bool          `json:"http_only"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+
+	// Expires is when the cookie expires, or the zero time for a session
+	// cookie.
+	Expires time.Time `json:"expires,omitempty"`
+
+	// Created is when the cookie was first set.
+	Created time.Time `json:"created"`
+}
+
+// key returns the key identifying the cookie in a jar.
+func (c *Cookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// expired returns true when the cookie expired before now.
+func (c *Cookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// CookieJar is an implementation of http.CookieJar which, unlike the jar in
+// net/http/cookiejar, can list, delete, save and load its cookies. Cookies
+// set for a domain in PublicSuffixes are refused.
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies map[string]*Cookie
+}
+
+// NewCookieJar creates and returns a new *CookieJar type.
+func NewCookieJar() *CookieJar {
+	return &CookieJar{
+		cookies: make(map[string]*Cookie),
+	}
+}
+
+// SetCookies implements the SetCookies method of the http.CookieJar
+// interface. Cookies with an invalid domain for the URL are ignored.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host := canonicalHost(u)
+	if host == "" {
+		return
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, hc := range cookies {
+		c, ok := j.newCookie(hc, host, u.Path, now)
+		if !ok {
+			continue
+		}
+		key := c.key()
+		if hc.MaxAge < 0 || c.expired(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if old, ok := j.cookies[key]; ok {
+			c.Created = old.Created
+		}
+		j.cookies[key] = c
+	}
+}
+
+// newCookie converts a cookie received from host into a *Cookie. Returns
+// false when the cookie domain is not valid for the host.
+func (j *CookieJar) newCookie(hc *http.Cookie, host, path string, now time.Time) (*Cookie, bool) {
+	c := &Cookie{
+		Name:     hc.Name,
+		Value:    hc.Value,
+		Path:     hc.Path,
+		Secure:   hc.Secure,
+		HttpOnly: hc.HttpOnly,
+		SameSite: hc.SameSite,
+		Created:  now,
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(hc.Domain), ".")
+	switch {
+	case domain == "":
+		c.Domain = host
+		c.HostOnly = true
+	case domain == host:
+		c.Domain = host
+		c.HostOnly = isIP(host) || isPublicSuffix(host)
+	case isIP(host) || !strings.HasSuffix(host, "."+domain):
+		return nil, false
+	case isPublicSuffix(domain):
+		// A host may not set cookies for its whole registry.
+		return nil, false
+	default:
+		c.Domain = domain
+	}
+
+	if c.Path == "" || c.Path[0] != '/' {
+		c.Path = defaultPath(path)
+	}
+	switch {
+	case hc.MaxAge > 0:
+		c.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+	case !hc.Expires.IsZero():
+		c.Expires = hc.Expires
+	}
+	return c, true
+}
+
+// Cookies implements the Cookies method of the http.CookieJar interface.
+// Cookies with longer paths are listed first.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	host := canonicalHost(u)
+	if host == "" {
+		return nil
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	var selected []*Cookie
+	for key, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if c.Secure && u.Scheme != "https" {
+			continue
+		}
+		if !c.matchesDomain(host) || !pathMatch(path, c.Path) {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	j.mu.Unlock()
+
+	sort.Slice(selected, func(a, b int) bool {
+		if len(selected[a].Path) != len(selected[b].Path) {
+			return len(selected[a].Path) > len(selected[b].Path)
+		}
+		if !selected[a].Created.Equal(selected[b].Created) {
+			return selected[a].Created.Before(selected[b].Created)
+		}
+		return selected[a].Name < selected[b].Name
+	})
+	cookies := make([]*http.Cookie, len(selected))
+	for i, c := range selected {
+		cookies[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return cookies
+}
+
+// matchesDomain returns true when the cookie is sent to the given host.
+func (c *Cookie) matchesDomain(host string) bool {
+	if c.HostOnly {
+		return host == c.Domain
+	}
+	return host == c.Domain || strings.HasSuffix(host, "."+c.Domain) && !isIP(host)
+}
+
+// All returns every cookie in the jar which has not expired, sorted by
+// domain, path and name.
+func (j *CookieJar) All() []*Cookie {
+	now := time.Now()
+	j.mu.Lock()
+	cookies := make([]*Cookie, 0, len(j.cookies))
+	for key, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		cc := *c
+		cookies = append(cookies, &cc)
+	}
+	j.mu.Unlock()
+
+	sort.Slice(cookies, func(a, b int) bool {
+		return cookies[a].key() < cookies[b].key()
+	})
+	return cookies
+}
+
+// Add stores the given cookie as is, replacing any cookie with the same
+// domain, path and name.
+func (j *CookieJar) Add(c *Cookie) {
+	cc := *c
+	cc.Domain = strings.TrimPrefix(strings.ToLower(cc.Domain), ".")
+	if cc.Path == "" {
+		cc.Path = "/"
+	}
+	if cc.Created.IsZero() {
+		cc.Created = time.Now()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cookies[cc.key()] = &cc
+}
+
+// Delete removes the cookie with the given domain, path and name.
+//
+// Returns a boolean value indicating whether the cookie existed.
+func (j *CookieJar) Delete(domain, path, name string) bool {
+	key := strings.TrimPrefix(strings.ToLower(domain), ".") + ";" + path + ";" + name
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.cookies[key]
+	delete(j.cookies, key)
+	return ok
+}
+
+// Clear removes every cookie from the jar.
+func (j *CookieJar) Clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cookies = make(map[string]*Cookie)
+}
+
+// Save writes every cookie in the jar to the given file as JSON.
+func (j *CookieJar) Save(file string) error {
+	b, err := json.MarshalIndent(j.All(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0600)
+}
+
+// Load adds the cookies saved with Save in the given file to the jar.
+func (j *CookieJar) Load(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var cookies []*Cookie
+	if err := json.Unmarshal(b, &cookies); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, c := range cookies {
+		if !c.expired(now) {
+			j.Add(c)
+		}
+	}
+	return nil
+}
+
+// httpOnlyPrefix marks HttpOnly cookies in the Netscape format, the way curl
+// writes them.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ExportNetscape writes every cookie in the jar in the Netscape cookies.txt
+// format used by curl and wget.
+func (j *CookieJar) ExportNetscape(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	fmt.Fprintln(bw, "# This file was generated by Surf. Edit at your own risk.")
+	fmt.Fprintln(bw)
+	for _, c := range j.All() {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!c.HostOnly), c.Path, netscapeBool(c.Secure),
+			expires, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
+
+// ImportNetscape adds the cookies read in the Netscape cookies.txt format
+// to the jar. Expired cookies are skipped.
+func (j *CookieJar) ImportNetscape(r io.Reader) error {
+	now := time.Now()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(text, httpOnlyPrefix) {
+			httpOnly = true
+			text = text[len(httpOnlyPrefix):]
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 7 {
+			return fmt.Errorf("cookies.txt line %d: expected 7 fields, got %d", line, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("cookies.txt line %d: invalid expiry %q", line, fields[4])
+		}
+		c := &Cookie{
+			Domain:   fields[0],
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+			Name:     fields[5],
+			Value:    strings.Join(fields[6:], "\t"),
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+			if c.expired(now) {
+				continue
+			}
+		}
+		j.Add(c)
+	}
+	return scanner.Err()
+}
+
+// SaveNetscape writes every cookie in the jar to the given file in the
+// Netscape cookies.txt format.
+func (j *CookieJar) SaveNetscape(file string) (err error) {
+	fout, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := fout.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return j.ExportNetscape(fout)
+}
+
+// LoadNetscape adds the cookies in the given Netscape cookies.txt file to
+// the jar.
+func (j *CookieJar) LoadNetscape(file string) error {
+	fin, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fin.Close()
+	return j.ImportNetscape(fin)
+}
+
+// netscapeBool formats a boolean the way the Netscape format expects.
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// canonicalHost returns the lower case host name of the URL, without the
+// port or a trailing dot.
+func canonicalHost(u *url.URL) string {
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// isIP returns true when the host is an IP address.
+func isIP(host string) bool {
+	return net.ParseIP(host) != nil
+}
+
+// defaultPath returns the default cookie path for the given request path,
+// as defined by RFC 6265 section 5.1.4.
+func defaultPath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// pathMatch returns true when the request path matches the cookie path, as
+// defined by RFC 6265 section 5.1.4.
+func pathMatch(reqPath, cookiePath string) bool {
+	if reqPath == cookiePath {
+		return true
+	}
+	if strings.HasPrefix(reqPath, cookiePath) {
+		return cookiePath[len(cookiePath)-1] == '/' || reqPath[len(cookiePath)] == '/'
+	}
+	return false
+}
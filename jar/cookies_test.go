@@ -0,0 +1,146 @@
+package jar
+
+import (
+	"bytes"
+	"github.com/Diggernaut/ut"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieJar(t *testing.T) {
+	ut.Run(t)
+	j := NewCookieJar()
+	u, _ := url.Parse("http://www.example.com/account/login")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true, HttpOnly: true},
+		{Name: "other", Value: "4", Domain: "other.com"},
+		{Name: "expired", Value: "5", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	ut.AssertEquals("host=1; domain=2", cookieString(j, "http://www.example.com/account/"))
+	ut.AssertEquals("domain=2", cookieString(j, "http://www.example.com/"))
+	ut.AssertEquals("domain=2", cookieString(j, "http://api.example.com/account/"))
+	ut.AssertEquals("domain=2; secure=3", cookieString(j, "https://www.example.com/"))
+	ut.AssertEquals("", cookieString(j, "http://other.com/"))
+
+	all := j.All()
+	ut.AssertEquals(3, len(all))
+	ut.AssertEquals("domain", all[0].Name)
+	ut.AssertEquals("example.com", all[0].Domain)
+	ut.AssertFalse(all[0].HostOnly)
+	ut.AssertEquals("secure", all[1].Name)
+	ut.AssertTrue(all[1].Secure)
+	ut.AssertTrue(all[1].HttpOnly)
+	ut.AssertEquals("host", all[2].Name)
+	ut.AssertEquals("/account", all[2].Path)
+	ut.AssertTrue(all[2].HostOnly)
+
+	ut.AssertTrue(j.Delete("www.example.com", "/account", "host"))
+	ut.AssertFalse(j.Delete("www.example.com", "/account", "host"))
+	ut.AssertEquals(2, len(j.All()))
+
+	j.SetCookies(u, []*http.Cookie{{Name: "domain", Domain: "example.com", Path: "/", MaxAge: -1}})
+	ut.AssertEquals(1, len(j.All()))
+
+	j.Clear()
+	ut.AssertEquals(0, len(j.All()))
+}
+
+func TestCookieJarFile(t *testing.T) {
+	ut.Run(t)
+	j := NewCookieJar()
+	u, _ := url.Parse("https://example.com/")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc", HttpOnly: true},
+		{Name: "pref", Value: "1", Domain: "example.com", MaxAge: 3600},
+	})
+
+	err := j.Save("./cookies.json")
+	ut.AssertNil(err)
+	defer func() {
+		err = os.Remove("./cookies.json")
+	}()
+	other := NewCookieJar()
+	err = other.Load("./cookies.json")
+	ut.AssertNil(err)
+	ut.AssertEquals(cookieString(j, "https://example.com/"), cookieString(other, "https://example.com/"))
+	ut.AssertEquals(2, len(other.All()))
+}
+
+func TestCookieJarNetscape(t *testing.T) {
+	ut.Run(t)
+	expires := time.Now().Add(time.Hour).Unix()
+	in := "# Netscape HTTP Cookie File\n" +
+		"\n" +
+		".example.com\tTRUE\t/\tFALSE\t" + itoa(expires) + "\tpref\t1\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/\tTRUE\t0\tsession\tabc\n" +
+		"old.example.com\tFALSE\t/\tFALSE\t1\told\tx\n"
+
+	j := NewCookieJar()
+	err := j.ImportNetscape(strings.NewReader(in))
+	ut.AssertNil(err)
+	ut.AssertEquals("pref=1; session=abc", cookieString(j, "https://www.example.com/"))
+	ut.AssertEquals("pref=1", cookieString(j, "http://api.example.com/"))
+
+	out := &bytes.Buffer{}
+	err = j.ExportNetscape(out)
+	ut.AssertNil(err)
+	ut.AssertContains(".example.com\tTRUE\t/\tFALSE\t"+itoa(expires)+"\tpref\t1\n", out.String())
+	ut.AssertContains("#HttpOnly_www.example.com\tFALSE\t/\tTRUE\t0\tsession\tabc\n", out.String())
+
+	other := NewCookieJar()
+	err = other.ImportNetscape(out)
+	ut.AssertNil(err)
+	ut.AssertEquals(2, len(other.All()))
+
+	err = other.ImportNetscape(strings.NewReader("example.com\tFALSE\t/\n"))
+	ut.AssertNotNil(err)
+}
+
+// cookieString returns the Cookie header the jar sends to the given URL.
+func cookieString(j http.CookieJar, rawurl string) string {
+	u, _ := url.Parse(rawurl)
+	var parts []string
+	for _, c := range j.Cookies(u) {
+		parts = append(parts, c.Name+"="+c.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func TestCookieJarPublicSuffix(t *testing.T) {
+	ut.Run(t)
+	j := NewCookieJar()
+	u, _ := url.Parse("http://www.example.co.uk/")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "registry", Value: "1", Domain: "co.uk"},
+		{Name: "site", Value: "2", Domain: "example.co.uk"},
+	})
+	ut.AssertEquals("site=2", cookieString(j, "http://www.example.co.uk/"))
+	ut.AssertEquals("", cookieString(j, "http://other.co.uk/"))
+
+	// A public suffix may only set host cookies for itself.
+	u, _ = url.Parse("http://github.io/")
+	j.SetCookies(u, []*http.Cookie{{Name: "suffix", Value: "3", Domain: "github.io"}})
+	ut.AssertEquals("suffix=3", cookieString(j, "http://github.io/"))
+	ut.AssertEquals("", cookieString(j, "http://user.github.io/"))
+
+	m := NewMemoryCookies()
+	u, _ = url.Parse("http://www.example.co.uk/")
+	m.SetCookies(u, []*http.Cookie{{Name: "registry", Value: "1", Domain: "co.uk"}})
+	ut.AssertEquals("", cookieString(m, "http://other.co.uk/"))
+
+	ut.AssertEquals("example.co.uk", Site("www.example.co.uk"))
+	ut.AssertEquals("127.0.0.1", Site("127.0.0.1"))
+	ut.AssertEquals("co.uk", Site("co.uk"))
+}
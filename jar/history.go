@@ -2,7 +2,9 @@ package jar
 
 import (
 	"net/http"
+	"net/url"
 	"sync"
+	"time"
 
 	"github.com/Diggernaut/goquery"
 )
@@ -12,6 +14,33 @@ type State struct {
 	Request  *http.Request
 	Response *http.Response
 	Dom      *goquery.Document
+
+	// Body is the page body, after decoding the content encoding and
+	// charset.
+	Body []byte
+
+	// Err is the error that ended the request, or nil. Response is nil when
+	// the request failed before a response was received.
+	Err error
+
+	// Redirects is the chain of redirects followed to reach Response, in
+	// the order they happened.
+	Redirects []*Redirect
+
+	// Time is when the state was recorded.
+	Time time.Time
+}
+
+// Redirect records one hop of a redirect chain.
+type Redirect struct {
+	// URL is the URL that answered with the redirect.
+	URL *url.URL
+
+	// StatusCode is the status of the redirect response, e.g. 302.
+	StatusCode int
+
+	// Location is the URL the redirect pointed to.
+	Location *url.URL
 }
 
 // NewHistoryState creates and returns a new *State type.
@@ -20,28 +49,88 @@ func NewHistoryState(req *http.Request, resp *http.Response, dom *goquery.Docume
 		Request:  req,
 		Response: resp,
 		Dom:      dom,
+		Time:     time.Now(),
+	}
+}
+
+// Entry returns a summary of the state for listing the history.
+func (s *State) Entry() *HistoryEntry {
+	e := &HistoryEntry{
+		Time: s.Time,
+	}
+	if s.Request != nil {
+		e.URL = s.Request.URL
+		e.Method = s.Request.Method
+	}
+	if s.Response != nil {
+		e.StatusCode = s.Response.StatusCode
+		if s.Response.Request != nil {
+			e.URL = s.Response.Request.URL
+		}
+	}
+	if s.Dom != nil {
+		e.Title = s.Dom.Find("title").Text()
 	}
+	return e
+}
+
+// HistoryEntry describes a page in the history.
+type HistoryEntry struct {
+	// URL is the page URL, after following redirects.
+	URL *url.URL
+
+	// Method is the HTTP method used to request the page.
+	Method string
+
+	// Title is the page title.
+	Title string
+
+	// StatusCode is the response status code, or 0 when the request failed.
+	StatusCode int
+
+	// Time is when the page was loaded.
+	Time time.Time
 }
 
 // History is a type that records browser state.
+//
+// The history works like the session history of a web browser. It holds the
+// visited pages in order, and a cursor pointing at the current page. Push
+// discards the pages after the cursor before adding a page.
 type History interface {
 	Len() int
 	SetCapacity(int)
 	Push(p *State) int
 	Pop() *State
 	Top() *State
-}
 
-// Node holds stack values and points to the next element.
-// type Node struct {
-//	Value *State
-//	Next  *Node
-// }
+	// Current returns the State at the cursor, or nil when empty.
+	Current() *State
+
+	// Index returns the position of the cursor, or -1 when empty.
+	Index() int
+
+	// Back moves the cursor one page back and returns the State there.
+	Back() *State
+
+	// Forward moves the cursor one page forward and returns the State there.
+	Forward() *State
+
+	// Go moves the cursor by delta pages and returns the State there.
+	// Returns nil and leaves the cursor alone when the move is out of range.
+	Go(delta int) *State
+
+	// Entries returns a summary of every page in the history, oldest first.
+	Entries() []*HistoryEntry
+}
 
 // MemoryHistory is an in-memory implementation of the History interface.
+//
+// Nothing is recorded until a capacity is set with SetCapacity.
 type MemoryHistory struct {
 	sync.Mutex
 	states   []*State
+	pos      int
 	Capacity int
 }
 
@@ -57,22 +146,28 @@ func (his *MemoryHistory) Len() int {
 	return len(his.states)
 }
 
-// Len returns the number of states in the history.
+// SetCapacity sets the maximum number of states kept in the history.
 func (his *MemoryHistory) SetCapacity(capacity int) {
 	his.Lock()
 	defer his.Unlock()
 	his.Capacity = capacity
 }
 
-// Push adds a new State at the front of the history.
+// Push discards the states after the cursor, then adds a new State at the
+// front of the history and moves the cursor to it.
 func (his *MemoryHistory) Push(p *State) int {
 	his.Lock()
 	defer his.Unlock()
 	if his.Capacity > 0 {
-		his.states = append(his.states, p)
+		for i := his.pos; i < len(his.states); i++ {
+			his.states[i] = nil
+		}
+		his.states = append(his.states[:his.pos], p)
 		if len(his.states) > his.Capacity {
+			his.states[0] = nil
 			his.states = his.states[1:]
 		}
+		his.pos = len(his.states)
 	}
 	return len(his.states)
 }
@@ -81,16 +176,16 @@ func (his *MemoryHistory) Push(p *State) int {
 func (his *MemoryHistory) Pop() *State {
 	his.Lock()
 	defer his.Unlock()
-	if len(his.states) > 0 {
-		value := his.states[len(his.states)-1]
-		if len(his.states) > 1 {
-			his.states[len(his.states)-1] = nil
-			his.states = his.states[0 : len(his.states)-1]
-		}
-		return value
+	if len(his.states) == 0 {
+		return nil
 	}
-
-	return nil
+	value := his.states[len(his.states)-1]
+	his.states[len(his.states)-1] = nil
+	his.states = his.states[0 : len(his.states)-1]
+	if his.pos > len(his.states) {
+		his.pos = len(his.states)
+	}
+	return value
 }
 
 // Top returns the State at the front of the history without removing it.
@@ -102,3 +197,54 @@ func (his *MemoryHistory) Top() *State {
 	}
 	return his.states[len(his.states)-1]
 }
+
+// Current returns the State at the cursor, or nil when empty.
+func (his *MemoryHistory) Current() *State {
+	his.Lock()
+	defer his.Unlock()
+	if his.pos == 0 {
+		return nil
+	}
+	return his.states[his.pos-1]
+}
+
+// Index returns the position of the cursor, or -1 when empty.
+func (his *MemoryHistory) Index() int {
+	his.Lock()
+	defer his.Unlock()
+	return his.pos - 1
+}
+
+// Back moves the cursor one page back and returns the State there.
+func (his *MemoryHistory) Back() *State {
+	return his.Go(-1)
+}
+
+// Forward moves the cursor one page forward and returns the State there.
+func (his *MemoryHistory) Forward() *State {
+	return his.Go(1)
+}
+
+// Go moves the cursor by delta pages and returns the State there.
+// Returns nil and leaves the cursor alone when the move is out of range.
+func (his *MemoryHistory) Go(delta int) *State {
+	his.Lock()
+	defer his.Unlock()
+	i := his.pos - 1 + delta
+	if his.pos == 0 || i < 0 || i >= len(his.states) {
+		return nil
+	}
+	his.pos = i + 1
+	return his.states[i]
+}
+
+// Entries returns a summary of every page in the history, oldest first.
+func (his *MemoryHistory) Entries() []*HistoryEntry {
+	his.Lock()
+	defer his.Unlock()
+	entries := make([]*HistoryEntry, len(his.states))
+	for i, s := range his.states {
+		entries[i] = s.Entry()
+	}
+	return entries
+}
@@ -1,13 +1,19 @@
 package jar
 
 import (
+	"bytes"
+	"github.com/Diggernaut/goquery"
 	"github.com/Diggernaut/ut"
+	"net/http"
+	"net/url"
+	"path/filepath"
 	"testing"
 )
 
 func TestMemoryHistory(t *testing.T) {
 	ut.Run(t)
 	stack := NewMemoryHistory()
+	stack.SetCapacity(10)
 
 	page1 := &State{}
 	stack.Push(page1)
@@ -27,4 +33,109 @@ func TestMemoryHistory(t *testing.T) {
 	page = stack.Pop()
 	ut.AssertEquals(page, page1)
 	ut.AssertEquals(0, stack.Len())
+	ut.AssertNil(stack.Pop())
+}
+
+func TestMemoryHistoryCursor(t *testing.T) {
+	ut.Run(t)
+	stack := NewMemoryHistory()
+	stack.SetCapacity(3)
+	ut.AssertNil(stack.Back())
+	ut.AssertEquals(-1, stack.Index())
+
+	pages := make([]*State, 4)
+	for i := range pages {
+		u, _ := url.Parse("http://localhost/" + string(rune('a'+i)))
+		pages[i] = NewHistoryState(&http.Request{Method: "GET", URL: u}, nil, nil)
+		stack.Push(pages[i])
+	}
+	ut.AssertEquals(3, stack.Len())
+	ut.AssertEquals(2, stack.Index())
+	ut.AssertEquals(pages[3], stack.Current())
+
+	ut.AssertEquals(pages[2], stack.Back())
+	ut.AssertEquals(pages[1], stack.Back())
+	ut.AssertNil(stack.Back())
+	ut.AssertEquals(pages[1], stack.Current())
+	ut.AssertEquals(pages[3], stack.Go(2))
+	ut.AssertNil(stack.Forward())
+	ut.AssertEquals(pages[2], stack.Go(-1))
+
+	// Pushing drops the pages after the cursor.
+	page := &State{}
+	stack.Push(page)
+	ut.AssertEquals(3, stack.Len())
+	ut.AssertEquals(page, stack.Current())
+	ut.AssertNil(stack.Forward())
+
+	entries := stack.Entries()
+	ut.AssertEquals(3, len(entries))
+	ut.AssertEquals("http://localhost/b", entries[0].URL.String())
+	ut.AssertEquals("GET", entries[0].Method)
+	ut.AssertEquals(pages[1].Time, entries[0].Time)
+}
+
+func TestCompactHistory(t *testing.T) {
+	ut.Run(t)
+	stack := NewCompactHistory()
+	stack.SetCapacity(10)
+	assertSnapshotHistory(stack)
+}
+
+func TestFileHistory(t *testing.T) {
+	ut.Run(t)
+	file := filepath.Join(t.TempDir(), "history.jsonl")
+	stack, err := NewFileHistory(file)
+	ut.AssertNil(err)
+	stack.SetCapacity(10)
+	assertSnapshotHistory(stack)
+	ut.AssertNil(stack.Err())
+
+	// The trail is loaded again from the file.
+	stack, err = NewFileHistory(file)
+	ut.AssertNil(err)
+	ut.AssertEquals(2, stack.Len())
+	ut.AssertEquals(1, stack.Index())
+	page := stack.Back()
+	ut.AssertNotNil(page)
+	ut.AssertEquals("Page 1", page.Dom.Find("title").Text())
+}
+
+// assertSnapshotHistory tests the given snapshot based history.
+func assertSnapshotHistory(stack History) {
+	pages := make([]*State, 2)
+	for i := range pages {
+		n := string(rune('1' + i))
+		u, _ := url.Parse("http://localhost/page" + n)
+		req := &http.Request{Method: "GET", URL: u}
+		resp := &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Request:    req,
+		}
+		body := []byte("<html><head><title>Page " + n + "</title></head></html>")
+		dom, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+		ut.AssertNil(err)
+		pages[i] = NewHistoryState(req, resp, dom)
+		pages[i].Body = body
+		stack.Push(pages[i])
+	}
+	ut.AssertEquals(2, stack.Len())
+	ut.AssertEquals(pages[1], stack.Current())
+
+	page := stack.Back()
+	ut.AssertNotNil(page)
+	ut.AssertEquals("Page 1", page.Dom.Find("title").Text())
+	ut.AssertEquals(string(pages[0].Body), string(page.Body))
+	ut.AssertEquals("http://localhost/page1", page.Response.Request.URL.String())
+	ut.AssertEquals(200, page.Response.StatusCode)
+	ut.AssertEquals("text/html", page.Response.Header.Get("Content-Type"))
+
+	page = stack.Forward()
+	ut.AssertNotNil(page)
+	ut.AssertEquals("Page 2", page.Dom.Find("title").Text())
+
+	entries := stack.Entries()
+	ut.AssertEquals("Page 1", entries[0].Title)
+	ut.AssertEquals("http://localhost/page2", entries[1].URL.String())
 }
@@ -0,0 +1,226 @@
+package jar
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookiePolicy decides which cookies a PolicyJar accepts.
+type CookiePolicy struct {
+	// BlockThirdParty refuses cookies set by a site other than the site of
+	// the first party URL, e.g. by a tracker redirecting back to the page.
+	BlockThirdParty bool
+
+	// Allow lists the domains whose cookies are always accepted, even when
+	// they are third-party cookies or their domain is denied. An entry
+	// matches the domain itself and all of its subdomains.
+	Allow []string
+
+	// Deny lists the domains whose cookies are refused. An entry matches the
+	// domain itself and all of its subdomains.
+	Deny []string
+
+	// MaxPerSite is the maximum number of cookies kept for a single site,
+	// which is a registrable domain such as "example.co.uk". New cookies
+	// over the limit are refused. There is no limit when zero.
+	MaxPerSite int
+
+	// Logger logs the refused cookies when it is not nil.
+	Logger *log.Logger
+}
+
+// PolicyJar is an http.CookieJar which only passes the cookies accepted by
+// a CookiePolicy on to the wrapped jar.
+type PolicyJar struct {
+	http.CookieJar
+	Policy *CookiePolicy
+
+	mu         sync.Mutex
+	firstParty *url.URL
+	sites      map[string]map[string]time.Time
+}
+
+// NewPolicyJar creates and returns a new *PolicyJar type wrapping cj.
+func NewPolicyJar(cj http.CookieJar, p *CookiePolicy) *PolicyJar {
+	return &PolicyJar{
+		CookieJar: cj,
+		Policy:    p,
+		sites:     make(map[string]map[string]time.Time),
+	}
+}
+
+// SetFirstParty sets the URL of the page the following requests are made
+// for. Cookies set by another site are third-party cookies. Every cookie is
+// a first party cookie when the URL is nil.
+func (j *PolicyJar) SetFirstParty(u *url.URL) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.firstParty = u
+}
+
+// SetCookies implements the SetCookies method of the http.CookieJar
+// interface. Cookies refused by the policy are dropped.
+func (j *PolicyJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if j.Policy == nil {
+		j.CookieJar.SetCookies(u, cookies)
+		return
+	}
+	host := canonicalHost(u)
+	site := Site(host)
+	now := time.Now()
+
+	j.mu.Lock()
+	counted := j.counted(site, now)
+	pending := make(map[string]bool, len(counted))
+	for k := range counted {
+		pending[k] = true
+	}
+	accepted := make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		if reason := j.check(u, host, site, c, now, pending); reason != "" {
+			if j.Policy.Logger != nil {
+				j.Policy.Logger.Printf("surf: refused cookie %q set by %s: %s", c.Name, u, reason)
+			}
+			continue
+		}
+		accepted = append(accepted, c)
+	}
+	j.mu.Unlock()
+
+	if len(accepted) == 0 {
+		return
+	}
+	j.CookieJar.SetCookies(u, accepted)
+
+	// The cookies are counted once the wrapped jar has stored them, so the
+	// counts match the cookies it holds.
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	counted = j.counted(site, now)
+	for _, c := range accepted {
+		key := cookieKey(host, c)
+		switch {
+		case isDeletion(c, now):
+			delete(counted, key)
+		case HasCookie(j.CookieJar, u, c):
+			counted[key] = cookieExpires(c, now)
+		}
+	}
+}
+
+// counted returns the cookies counted against the limit of the site, keyed
+// by domain, path and name, without the cookies which have expired.
+func (j *PolicyJar) counted(site string, now time.Time) map[string]time.Time {
+	counted := j.sites[site]
+	if counted == nil {
+		counted = make(map[string]time.Time)
+		j.sites[site] = counted
+	}
+	for k, expires := range counted {
+		if !expires.IsZero() && !expires.After(now) {
+			delete(counted, k)
+		}
+	}
+	return counted
+}
+
+// check returns the reason the policy refuses the cookie, or an empty string
+// when the cookie is accepted. The pending cookies of the site, which are
+// the counted cookies changed by the cookies accepted so far, are updated
+// when the cookie is accepted.
+func (j *PolicyJar) check(u *url.URL, host, site string, c *http.Cookie, now time.Time, pending map[string]bool) string {
+	domain := cookieDomain(host, c)
+	allowed := matchDomains(domain, j.Policy.Allow) || matchDomains(host, j.Policy.Allow)
+	if !allowed {
+		if matchDomains(domain, j.Policy.Deny) || matchDomains(host, j.Policy.Deny) {
+			return "domain denied"
+		}
+		if j.Policy.BlockThirdParty && j.firstParty != nil && Site(canonicalHost(j.firstParty)) != site {
+			return "third-party cookie from " + site + " on " + j.firstParty.Host
+		}
+	}
+
+	key := cookieKey(host, c)
+	if isDeletion(c, now) {
+		delete(pending, key)
+		return ""
+	}
+	if !pending[key] && j.Policy.MaxPerSite > 0 && len(pending) >= j.Policy.MaxPerSite {
+		return "too many cookies for " + site
+	}
+	pending[key] = true
+	return ""
+}
+
+// HasCookie returns true when the jar holds the cookie set by the response
+// from u, with the value it was set with. Use it to find whether a jar
+// stored or refused a cookie.
+func HasCookie(cj http.CookieJar, u *url.URL, c *http.Cookie) bool {
+	cu := &url.URL{Scheme: u.Scheme, Host: cookieDomain(canonicalHost(u), c), Path: c.Path}
+	if c.Secure {
+		cu.Scheme = "https"
+	}
+	if !strings.HasPrefix(cu.Path, "/") {
+		cu.Path = u.Path
+		if i := strings.LastIndex(cu.Path, "/"); i > 0 {
+			cu.Path = cu.Path[:i]
+		}
+		if cu.Path == "" {
+			cu.Path = "/"
+		}
+	}
+	for _, sc := range cj.Cookies(cu) {
+		if sc.Name == c.Name && sc.Value == c.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// isDeletion returns true when setting the cookie deletes it.
+func isDeletion(c *http.Cookie, now time.Time) bool {
+	return c.MaxAge < 0 || !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// cookieDomain returns the domain of the cookie, which is the host setting
+// it for a host-only cookie.
+func cookieDomain(host string, c *http.Cookie) string {
+	domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+	if domain == "" {
+		domain = host
+	}
+	return domain
+}
+
+// cookieKey returns the key the cookie is counted with.
+func cookieKey(host string, c *http.Cookie) string {
+	return cookieDomain(host, c) + ";" + c.Path + ";" + c.Name
+}
+
+// cookieExpires returns the time the cookie expires, or the zero time for a
+// session cookie.
+func cookieExpires(c *http.Cookie, now time.Time) time.Time {
+	switch {
+	case c.MaxAge > 0:
+		return now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		return c.Expires
+	}
+	return time.Time{}
+}
+
+// matchDomains returns true when the domain is one of the given domains or
+// one of their subdomains.
+func matchDomains(domain string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(d), ".")
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package jar
+
+import (
+	"bytes"
+	"github.com/Diggernaut/ut"
+	"log"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestPolicyJar(t *testing.T) {
+	ut.Run(t)
+	logs := &bytes.Buffer{}
+	j := NewPolicyJar(NewCookieJar(), &CookiePolicy{
+		BlockThirdParty: true,
+		Allow:           []string{"sso.example.net"},
+		Deny:            []string{"ads.example.com"},
+		MaxPerSite:      2,
+		Logger:          log.New(logs, "", 0),
+	})
+
+	first, _ := url.Parse("https://www.example.com/")
+	j.SetFirstParty(first)
+	j.SetCookies(first, []*http.Cookie{{Name: "a", Value: "1"}})
+	ads, _ := url.Parse("https://ads.example.com/")
+	j.SetCookies(ads, []*http.Cookie{{Name: "ad", Value: "1"}})
+	ut.AssertEquals("a=1", cookieString(j, "https://www.example.com/"))
+	ut.AssertEquals("", cookieString(j, "https://ads.example.com/"))
+	ut.AssertContains(`refused cookie "ad" set by https://ads.example.com/: domain denied`, logs.String())
+
+	tracker, _ := url.Parse("https://tracker.example.org/")
+	j.SetCookies(tracker, []*http.Cookie{{Name: "id", Value: "1"}})
+	ut.AssertEquals("", cookieString(j, "https://tracker.example.org/"))
+	ut.AssertContains("third-party cookie from example.org", logs.String())
+
+	sso, _ := url.Parse("https://sso.example.net/")
+	j.SetCookies(sso, []*http.Cookie{{Name: "sso", Value: "1"}})
+	ut.AssertEquals("sso=1", cookieString(j, "https://sso.example.net/"))
+
+	j.SetCookies(first, []*http.Cookie{
+		{Name: "b", Value: "2"},
+		{Name: "c", Value: "3"},
+	})
+	ut.AssertEquals("a=1; b=2", cookieString(j, "https://www.example.com/"))
+	ut.AssertContains("too many cookies for example.com", logs.String())
+
+	// Replacing and deleting cookies is not limited.
+	j.SetCookies(first, []*http.Cookie{
+		{Name: "a", Value: "4"},
+		{Name: "b", MaxAge: -1},
+		{Name: "c", Value: "3"},
+	})
+	ut.AssertEquals("a=4; c=3", cookieString(j, "https://www.example.com/"))
+
+	j.SetFirstParty(nil)
+	j.SetCookies(tracker, []*http.Cookie{{Name: "id", Value: "1"}})
+	ut.AssertEquals("id=1", cookieString(j, "https://tracker.example.org/"))
+
+	// Cookies refused by the wrapped jar are not counted.
+	j = NewPolicyJar(NewCookieJar(), &CookiePolicy{MaxPerSite: 1})
+	j.SetCookies(first, []*http.Cookie{{Name: "x", Value: "1", Domain: "example.org"}})
+	ut.AssertEquals("", cookieString(j, "https://www.example.com/"))
+	j.SetCookies(first, []*http.Cookie{{Name: "a", Value: "1"}})
+	ut.AssertEquals("a=1", cookieString(j, "https://www.example.com/"))
+}
@@ -0,0 +1,388 @@
+package jar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Diggernaut/goquery"
+	"github.com/dataxpe/surf/util"
+)
+
+// Snapshot is a compact record of a State, which may be serialized as JSON.
+//
+// The page body is stored gzip compressed, and the DOM is rebuilt from it
+// when the snapshot is turned back into a State.
+type Snapshot struct {
+	URL        string      `json:"url"`
+	Method     string      `json:"method"`
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Title      string      `json:"title,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Time       time.Time   `json:"time"`
+}
+
+// NewSnapshot creates and returns a *Snapshot of the given state.
+func NewSnapshot(s *State) (*Snapshot, error) {
+	e := s.Entry()
+	sn := &Snapshot{
+		Method:     e.Method,
+		StatusCode: e.StatusCode,
+		Title:      e.Title,
+		Time:       e.Time,
+	}
+	if e.URL != nil {
+		sn.URL = e.URL.String()
+	}
+	if s.Response != nil {
+		sn.Header = s.Response.Header
+	}
+	if s.Err != nil {
+		sn.Error = s.Err.Error()
+	}
+	if len(s.Body) > 0 {
+		buff := &bytes.Buffer{}
+		w := gzip.NewWriter(buff)
+		if _, err := w.Write(s.Body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		sn.Body = buff.Bytes()
+	}
+	return sn, nil
+}
+
+// State rebuilds the State recorded by the snapshot, parsing the DOM from
+// the page body.
+//
+// The rebuilt State does not hold the error that ended the request, only
+// its message in the snapshot. Nor does its request hold the body that was
+// sent, so a POST request cannot be sent again.
+func (sn *Snapshot) State() (*State, error) {
+	u, err := url.Parse(sn.URL)
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	if len(sn.Body) > 0 {
+		r, err := gzip.NewReader(bytes.NewReader(sn.Body))
+		if err != nil {
+			return nil, err
+		}
+		body, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dom, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: sn.Method,
+		URL:    u,
+		Host:   u.Host,
+		Header: make(http.Header),
+	}
+	var resp *http.Response
+	if sn.StatusCode != 0 {
+		resp = &http.Response{
+			StatusCode: sn.StatusCode,
+			Status:     http.StatusText(sn.StatusCode),
+			Header:     sn.Header,
+			Request:    req,
+		}
+	}
+	return &State{
+		Request:  req,
+		Response: resp,
+		Dom:      dom,
+		Body:     body,
+		Time:     sn.Time,
+	}, nil
+}
+
+// Entry returns a summary of the snapshot for listing the history.
+func (sn *Snapshot) Entry() *HistoryEntry {
+	u, _ := url.Parse(sn.URL)
+	return &HistoryEntry{
+		URL:        u,
+		Method:     sn.Method,
+		Title:      sn.Title,
+		StatusCode: sn.StatusCode,
+		Time:       sn.Time,
+	}
+}
+
+// ReadSnapshots reads the snapshots written as JSON lines by FileHistory.
+func ReadSnapshots(r io.Reader) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	dec := json.NewDecoder(r)
+	for {
+		sn := &Snapshot{}
+		err := dec.Decode(sn)
+		if err == io.EOF {
+			return snapshots, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, sn)
+	}
+}
+
+// CompactHistory is an implementation of the History interface that stores
+// a Snapshot of each page instead of the live response and DOM. The DOM is
+// rebuilt when moving back or forward to a page.
+//
+// Nothing is recorded until a capacity is set with SetCapacity.
+type CompactHistory struct {
+	sync.Mutex
+	snapshots []*Snapshot
+	current   *State
+	pos       int
+	Capacity  int
+}
+
+// NewCompactHistory creates and returns a new *CompactHistory type.
+func NewCompactHistory() *CompactHistory {
+	return &CompactHistory{}
+}
+
+// Len returns the number of states in the history.
+func (his *CompactHistory) Len() int {
+	his.Lock()
+	defer his.Unlock()
+	return len(his.snapshots)
+}
+
+// SetCapacity sets the maximum number of states kept in the history.
+func (his *CompactHistory) SetCapacity(capacity int) {
+	his.Lock()
+	defer his.Unlock()
+	his.Capacity = capacity
+	his.trim()
+}
+
+// Push discards the states after the cursor, then adds a snapshot of the
+// State at the front of the history and moves the cursor to it.
+func (his *CompactHistory) Push(p *State) int {
+	sn, err := NewSnapshot(p)
+	if err != nil {
+		return his.Len()
+	}
+	return his.push(sn, p)
+}
+
+// push adds the snapshot of the given state.
+func (his *CompactHistory) push(sn *Snapshot, p *State) int {
+	his.Lock()
+	defer his.Unlock()
+	if his.Capacity > 0 {
+		for i := his.pos; i < len(his.snapshots); i++ {
+			his.snapshots[i] = nil
+		}
+		his.snapshots = append(his.snapshots[:his.pos], sn)
+		his.pos = len(his.snapshots)
+		his.current = p
+		his.trim()
+	}
+	return len(his.snapshots)
+}
+
+// trim drops the oldest snapshots above the capacity.
+func (his *CompactHistory) trim() {
+	if his.Capacity <= 0 || len(his.snapshots) <= his.Capacity {
+		return
+	}
+	n := len(his.snapshots) - his.Capacity
+	for i := 0; i < n; i++ {
+		his.snapshots[i] = nil
+	}
+	his.snapshots = his.snapshots[n:]
+	his.pos -= n
+	if his.pos <= 0 {
+		his.pos = 0
+		his.current = nil
+	}
+}
+
+// Pop removes and returns the State at the front of the history.
+func (his *CompactHistory) Pop() *State {
+	his.Lock()
+	defer his.Unlock()
+	if len(his.snapshots) == 0 {
+		return nil
+	}
+	state := his.state(len(his.snapshots) - 1)
+	his.snapshots[len(his.snapshots)-1] = nil
+	his.snapshots = his.snapshots[0 : len(his.snapshots)-1]
+	if his.pos > len(his.snapshots) {
+		his.pos = len(his.snapshots)
+		his.current = nil
+	}
+	return state
+}
+
+// Top returns the State at the front of the history without removing it.
+func (his *CompactHistory) Top() *State {
+	his.Lock()
+	defer his.Unlock()
+	if len(his.snapshots) == 0 {
+		return nil
+	}
+	return his.state(len(his.snapshots) - 1)
+}
+
+// Current returns the State at the cursor, or nil when empty.
+func (his *CompactHistory) Current() *State {
+	his.Lock()
+	defer his.Unlock()
+	if his.pos == 0 {
+		return nil
+	}
+	return his.state(his.pos - 1)
+}
+
+// Index returns the position of the cursor, or -1 when empty.
+func (his *CompactHistory) Index() int {
+	his.Lock()
+	defer his.Unlock()
+	return his.pos - 1
+}
+
+// Back moves the cursor one page back and returns the State there.
+func (his *CompactHistory) Back() *State {
+	return his.Go(-1)
+}
+
+// Forward moves the cursor one page forward and returns the State there.
+func (his *CompactHistory) Forward() *State {
+	return his.Go(1)
+}
+
+// Go moves the cursor by delta pages and returns the State there.
+// Returns nil and leaves the cursor alone when the move is out of range, or
+// when the page cannot be rebuilt from its snapshot.
+func (his *CompactHistory) Go(delta int) *State {
+	his.Lock()
+	defer his.Unlock()
+	i := his.pos - 1 + delta
+	if his.pos == 0 || i < 0 || i >= len(his.snapshots) {
+		return nil
+	}
+	state := his.state(i)
+	if state == nil {
+		return nil
+	}
+	his.pos = i + 1
+	his.current = state
+	return state
+}
+
+// Entries returns a summary of every page in the history, oldest first.
+func (his *CompactHistory) Entries() []*HistoryEntry {
+	his.Lock()
+	defer his.Unlock()
+	entries := make([]*HistoryEntry, len(his.snapshots))
+	for i, sn := range his.snapshots {
+		entries[i] = sn.Entry()
+	}
+	return entries
+}
+
+// state returns the State at the given position, reusing the live State
+// at the cursor instead of rebuilding it.
+func (his *CompactHistory) state(i int) *State {
+	if i == his.pos-1 && his.current != nil {
+		return his.current
+	}
+	state, err := his.snapshots[i].State()
+	if err != nil {
+		return nil
+	}
+	return state
+}
+
+// FileHistory is a CompactHistory that also appends each snapshot to a file
+// as a line of JSON. The file keeps every page pushed, regardless of the
+// capacity and of moves back and forward, so it can be inspected afterwards
+// with ReadSnapshots.
+type FileHistory struct {
+	*CompactHistory
+	file string
+	err  error
+}
+
+// NewFileHistory creates and returns a new *FileHistory type.
+//
+// The snapshots already in the file are loaded into the history, with the
+// cursor on the last one, so a browsing trail survives a restart.
+func NewFileHistory(file string) (*FileHistory, error) {
+	his := &FileHistory{
+		CompactHistory: NewCompactHistory(),
+		file:           file,
+	}
+	if util.FileExists(file) {
+		fin, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer fin.Close()
+		snapshots, err := ReadSnapshots(fin)
+		if err != nil {
+			return nil, err
+		}
+		his.snapshots = snapshots
+		his.pos = len(snapshots)
+	}
+	return his, nil
+}
+
+// Push appends a snapshot of the State to the file, then adds it to the
+// history. Use Err to check whether writing the file failed.
+func (his *FileHistory) Push(p *State) int {
+	sn, err := NewSnapshot(p)
+	if err != nil {
+		his.err = err
+		return his.Len()
+	}
+	his.err = his.writeToFile(sn)
+	return his.push(sn, p)
+}
+
+// Err returns the error of the last failed write to the file, or nil.
+func (his *FileHistory) Err() error {
+	return his.err
+}
+
+// writeToFile appends the snapshot to the file.
+func (his *FileHistory) writeToFile(sn *Snapshot) (err error) {
+	j, err := json.Marshal(sn)
+	if err != nil {
+		return err
+	}
+	fout, err := os.OpenFile(his.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := fout.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = fout.Write(append(j, '\n'))
+	return err
+}
@@ -21,6 +21,15 @@ var (
 
 	// DefaultFollowRedirects is the global value for the AttributeFollowRedirects attribute.
 	DefaultFollowRedirects = true
+
+	// DefaultSyntheticErrorPages is the global value for the SyntheticErrorPages attribute.
+	DefaultSyntheticErrorPages = false
+
+	// DefaultValidateForms is the global value for the ValidateForms attribute.
+	DefaultValidateForms = false
+
+	// DefaultRunScripts is the global value for the RunScripts attribute.
+	DefaultRunScripts = false
 )
 
 // NewBrowser creates and returns a *browser.Browser type.
@@ -39,6 +48,9 @@ func NewBrowser() *browser.Browser {
 		browser.SendReferer:         DefaultSendReferer,
 		browser.MetaRefreshHandling: DefaultMetaRefreshHandling,
 		browser.FollowRedirects:     DefaultFollowRedirects,
+		browser.SyntheticErrorPages: DefaultSyntheticErrorPages,
+		browser.ValidateForms:       DefaultValidateForms,
+		browser.RunScripts:          DefaultRunScripts,
 	})
 	bow.InitConverters()
 